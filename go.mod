@@ -5,11 +5,11 @@ go 1.22.5
 require (
 	github.com/jedib0t/go-pretty/v6 v6.5.9
 	golang.org/x/crypto v0.26.0
+	golang.org/x/sys v0.23.0
 	golang.org/x/term v0.23.0
 )
 
 require (
 	github.com/mattn/go-runewidth v0.0.15 // indirect
 	github.com/rivo/uniseg v0.2.0 // indirect
-	golang.org/x/sys v0.23.0 // indirect
 )
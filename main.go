@@ -1,15 +1,34 @@
 package main
 
 import (
+	"flag"
 	"fmt"
+	"os"
+	audithandler "work-mini-project/pkg/auditHandler"
 	clihandler "work-mini-project/pkg/cliHandler"
 	commandhandler "work-mini-project/pkg/commandHandler"
 	"work-mini-project/pkg/configuration"
 	crmhandler "work-mini-project/pkg/crmHandler"
 	customerhandler "work-mini-project/pkg/customerHandler"
+	deliveryhandler "work-mini-project/pkg/deliveryHandler"
+	filehandler "work-mini-project/pkg/fileHandler"
+	maphandler "work-mini-project/pkg/mapHandler"
 	transporthandler "work-mini-project/pkg/transportHandler"
 )
 
+type dataFile struct {
+	filePath   string
+	migrations []filehandler.Migration
+}
+
+func dataFiles(config *configuration.Config) []dataFile {
+	return []dataFile{
+		{config.Customers.FilePath, customerhandler.Migrations},
+		{config.Users.FilePath, crmhandler.Migrations},
+		{config.Deliveries.FilePath, deliveryhandler.Migrations},
+	}
+}
+
 func StartApp(commandHandler *commandhandler.CommandHandler) {
 	for {
 		err := commandHandler.Handle()
@@ -19,27 +38,187 @@ func StartApp(commandHandler *commandhandler.CommandHandler) {
 	}
 }
 
+// Print the migrations that would be applied to each data file on next load, without applying them.
+func reportMigrations(config *configuration.Config) error {
+	fileCipher, err := config.DataFileCipher()
+	if err != nil {
+		return err
+	}
+
+	for _, file := range dataFiles(config) {
+		report, err := filehandler.PlanMigrations(file.filePath, file.migrations, fileCipher)
+		if err != nil {
+			return err
+		}
+
+		fmt.Println(report)
+	}
+
+	return nil
+}
+
+// Encrypt or decrypt each data file in place, using the configured key regardless of whether encryption is enabled.
+func convertDataFiles(config *configuration.Config, encrypt bool) error {
+	fileCipher, err := filehandler.LoadCipher(config.Encryption.KeyEnvVar, config.Encryption.KeyFilePath)
+	if err != nil {
+		return err
+	}
+
+	convert, action := filehandler.DecryptFile, "decrypted"
+	if encrypt {
+		convert, action = filehandler.EncryptFile, "encrypted"
+	}
+
+	for _, file := range dataFiles(config) {
+		converted, err := convert(file.filePath, fileCipher)
+		if err != nil {
+			return err
+		}
+
+		if converted {
+			fmt.Printf("%s: %s\n", file.filePath, action)
+		} else {
+			fmt.Printf("%s: already %s\n", file.filePath, action)
+		}
+	}
+
+	return nil
+}
+
+// Export a map of every customer and the routes of every booked delivery, colour-coded by transport method.
+func exportMap(config *configuration.Config, customerHandler *customerhandler.CustomerHandler, filePath string) error {
+	deliveryHandler, err := deliveryhandler.New(config)
+	if err != nil {
+		return err
+	}
+
+	transportHandler := transporthandler.New(config)
+	gridMap := maphandler.New(config, customerHandler.ActiveCustomers())
+
+	for _, delivery := range deliveryHandler.Deliveries {
+		customer, err := customerHandler.GetCustomerByID(delivery.CustomerID)
+		if err != nil || customer.IsDeleted() {
+			continue
+		}
+
+		gridMap.AddRoute(*customer, &transporthandler.TripDetails{
+			Method: delivery.Method,
+			Route:  transportHandler.Route(*customer, delivery.Method),
+		})
+	}
+
+	return gridMap.Export(filePath)
+}
+
+// Check the audit log's hash chain, and its signatures if a key is configured, reporting the first broken link.
+func verifyAuditLog(config *configuration.Config) error {
+	auditLog, err := audithandler.New(config)
+	if err != nil {
+		return err
+	}
+
+	count, lastHash, err := auditLog.Verify()
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%s: %d entries intact, last hash %s\n", config.Audit.FilePath, count, lastHash)
+
+	return nil
+}
+
 func main() {
+	migrateDryRun := flag.Bool("migrate-dry-run", false, "report data file migrations that would be applied, then exit")
+	encryptData := flag.Bool("encrypt-data", false, "encrypt the data files in place with the configured key, then exit")
+	decryptData := flag.Bool("decrypt-data", false, "decrypt the data files in place with the configured key, then exit")
+	exportCustomers := flag.String(
+		"export-customers", "", "export all customers to the given .csv or .json file, then exit",
+	)
+	exportMapPath := flag.String(
+		"export-map", "", "export a map of customers and delivery routes to the given .svg or .png file, then exit",
+	)
+	verifyAudit := flag.Bool("verify-audit", false, "verify the audit log hasn't been altered, then exit")
+	flag.Parse()
+
 	config, err := configuration.LoadConfig()
 	if err != nil {
 		panic(err)
 	}
 
-	cliHandler := clihandler.New()
+	if *migrateDryRun {
+		err = reportMigrations(config)
+		if err != nil {
+			panic(err)
+		}
 
-	crmHandler, err := crmhandler.New(config, cliHandler)
+		return
+	}
+
+	if *verifyAudit {
+		err = verifyAuditLog(config)
+		if err != nil {
+			// Exit with a failure status rather than panicking, so the check can be scripted
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+		return
+	}
+
+	if *encryptData || *decryptData {
+		err = convertDataFiles(config, *encryptData)
+		if err != nil {
+			panic(err)
+		}
+
+		return
+	}
+
+	customerHandler, err := customerhandler.New(config)
 	if err != nil {
 		panic(err)
 	}
 
-	customerHandler, err := customerhandler.New(config)
+	if *exportCustomers != "" {
+		// Run without logging in, by someone who can already read the data files, so nothing is left out
+		exported, err := customerHandler.ExportCustomers(*exportCustomers, true)
+		if err != nil {
+			panic(err)
+		}
+
+		fmt.Printf("Exported %d customer(s) to %s\n", exported, *exportCustomers)
+
+		return
+	}
+
+	if *exportMapPath != "" {
+		err = exportMap(config, customerHandler, *exportMapPath)
+		if err != nil {
+			panic(err)
+		}
+
+		fmt.Printf("Exported map to %s\n", *exportMapPath)
+
+		return
+	}
+
+	cliHandler := clihandler.New()
+
+	crmHandler, err := crmhandler.New(config, cliHandler)
 	if err != nil {
 		panic(err)
 	}
 
 	transportHandler := transporthandler.New(config)
 
-	commandHandler := commandhandler.New(config, cliHandler, crmHandler, customerHandler, transportHandler)
+	deliveryHandler, err := deliveryhandler.New(config)
+	if err != nil {
+		panic(err)
+	}
+
+	commandHandler := commandhandler.New(
+		config, cliHandler, crmHandler, customerHandler, transportHandler, deliveryHandler,
+	)
 
 	cliHandler.ClearTerminal()
 
@@ -0,0 +1,601 @@
+package audithandler
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"time"
+	"work-mini-project/pkg/configuration"
+	filehandler "work-mini-project/pkg/fileHandler"
+)
+
+// Actions recorded in the audit log.
+const (
+	ActionLogin            = "login"
+	ActionLoginFailed      = "login.failed"
+	ActionLogout           = "logout"
+	ActionCustomerAdd      = "customer.add"
+	ActionCustomerUpdate   = "customer.update"
+	ActionCustomerRemove   = "customer.remove"
+	ActionCustomerRestore  = "customer.restore"
+	ActionCustomerPurge    = "customer.purge"
+	ActionUserAdd          = "user.add"
+	ActionUserRemove       = "user.remove"
+	ActionUserRole         = "user.role"
+	ActionUserRestore      = "user.restore"
+	ActionUserPurge        = "user.purge"
+	ActionUserApprove      = "user.approve"
+	ActionUserReject       = "user.reject"
+	ActionUserUnlock       = "user.unlock"
+	ActionPasswordChange   = "user.password.change"
+	ActionPasswordReset    = "user.password.reset"
+	ActionTwoFactorEnable  = "user.twoFactor.enable"
+	ActionTwoFactorDisable = "user.twoFactor.disable"
+	ActionTwoFactorReset   = "user.twoFactor.reset"
+	ActionInviteCreate     = "invite.create"
+	ActionDeliveryBook     = "delivery.book"
+	ActionAuditKeyEnabled  = "audit.key.enabled" // Entries from here on are signed with the current key
+)
+
+// An Entry records a single action, with the state of its target before and after the action where relevant.
+type Entry struct {
+	Timestamp  time.Time       `json:"timestamp"`
+	ActorID    string          `json:"actorId,omitempty"` // ID of the user who performed the action, if logged in
+	Action     string          `json:"action"`
+	TargetID   string          `json:"targetId,omitempty"`
+	TargetName string          `json:"targetName,omitempty"`
+	Detail     string          `json:"detail,omitempty"`
+	Before     json.RawMessage `json:"before,omitempty"`
+	After      json.RawMessage `json:"after,omitempty"`
+	Fields     []string        `json:"fields,omitempty"`   // Names of the fields changed, without their values
+	PrevHash   string          `json:"prevHash,omitempty"` // SHA-256 of the previous line, blank for the first entry
+	HMAC       string          `json:"hmac,omitempty"`     // Signature of the rest of the line, if a key is configured
+}
+
+// AuditLog appends entries to a JSON Lines file. Entries are never modified or removed, and each includes
+// the hash of the one before, so any change to the log breaks the chain.
+type AuditLog struct {
+	filePath string
+	hmacKey  []byte
+}
+
+// Shortest HMAC key accepted, the output size of SHA-256.
+const minHMACKeyLength = 32
+
+var errMissingHMACKey = errors.New("an audit log HMAC key is configured but was not provided")
+
+var errHMACKeyTooShort = fmt.Errorf("audit log HMAC key must be at least %d bytes", minHMACKeyLength)
+
+var errBrokenChain = errors.New("audit log chain broken")
+
+var errPrevHashMismatch = errors.New("previous hash doesn't match the entry before it")
+
+var errMissingHMAC = errors.New("entry is not signed")
+
+var errHMACMismatch = errors.New("signature doesn't match the entry")
+
+var errUnsignedLastEntry = errors.New("last entry is not signed with the current key, so the log may have been altered")
+
+func wrapError(err error) error {
+	return fmt.Errorf("auditHandler: %w", err)
+}
+
+// New returns an audit log writing to the configured file, or discarding entries if the path is blank.
+// If an HMAC key environment variable or file is configured, the key must be provided.
+func New(config *configuration.Config) (*AuditLog, error) {
+	auditLog := &AuditLog{filePath: config.Audit.FilePath}
+
+	if config.Audit.HMACKeyEnvVar == "" && config.Audit.HMACKeyFilePath == "" {
+		return auditLog, nil
+	}
+
+	key, err := filehandler.LoadKey(config.Audit.HMACKeyEnvVar, config.Audit.HMACKeyFilePath)
+	if err != nil {
+		return nil, wrapError(err)
+	}
+
+	if key == nil {
+		return nil, wrapError(errMissingHMACKey)
+	}
+
+	if len(key) < minHMACKeyLength {
+		return nil, wrapError(errHMACKeyTooShort)
+	}
+
+	auditLog.hmacKey = key
+
+	return auditLog, nil
+}
+
+func hashLine(line []byte) string {
+	hash := sha256.Sum256(line)
+
+	return hex.EncodeToString(hash[:])
+}
+
+func (auditLog *AuditLog) sign(unsigned []byte) string {
+	mac := hmac.New(sha256.New, auditLog.hmacKey)
+	mac.Write(unsigned)
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// The HMAC is always the last field of a line, so the signed line is the unsigned one with it appended.
+func signatureSuffix(signature string) []byte {
+	return []byte(`,"hmac":"` + signature + `"}`)
+}
+
+// Size of the chunks read back from the end of the file to find its last line.
+const tailChunkSize = 4096
+
+// Read the last line of the file, without its newline. Returns nil if the file is empty.
+func lastLine(file *os.File) ([]byte, error) {
+	info, err := file.Stat()
+	if err != nil {
+		return nil, wrapError(err)
+	}
+
+	tail := []byte{}
+
+	for offset := info.Size(); offset > 0; {
+		chunk := make([]byte, min(offset, tailChunkSize))
+		offset -= int64(len(chunk))
+
+		_, err = file.ReadAt(chunk, offset)
+		if err != nil {
+			return nil, wrapError(err)
+		}
+
+		tail = append(chunk, tail...)
+
+		line := bytes.TrimSuffix(tail, []byte("\n"))
+		if index := bytes.LastIndexByte(line, '\n'); index != -1 {
+			return line[index+1:], nil
+		}
+	}
+
+	return bytes.TrimSuffix(tail, []byte("\n")), nil
+}
+
+// Marshal a before or after value, leaving it out of the entry if nil.
+func marshalValue(value any) (json.RawMessage, error) {
+	if value == nil {
+		return nil, nil
+	}
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, wrapError(err)
+	}
+
+	return data, nil
+}
+
+// Record appends an entry to the log, timestamped now, with the target's state before and after the action.
+// Pass nil for before when a record is created, and for after when it is permanently deleted.
+func (auditLog *AuditLog) Record(entry Entry, before any, after any) error {
+	if auditLog.filePath == "" {
+		return nil
+	}
+
+	var err error
+
+	entry.Timestamp = time.Now()
+
+	entry.Before, err = marshalValue(before)
+	if err != nil {
+		return err
+	}
+
+	entry.After, err = marshalValue(after)
+	if err != nil {
+		return err
+	}
+
+	//nolint:mnd // Log file permissions, read/write for owner only
+	file, err := os.OpenFile(auditLog.filePath, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0o600)
+	if err != nil {
+		return wrapError(err)
+	}
+
+	// Every handler, and every instance of the app, appends to the same file, so hold an exclusive lock
+	// from reading the last line until the new one is written, or two entries could link to the same line
+	err = lockFile(file)
+	if err != nil {
+		_ = file.Close()
+
+		return wrapError(err)
+	}
+
+	err = auditLog.appendEntry(file, entry)
+	if err != nil {
+		_ = unlockFile(file)
+		_ = file.Close()
+
+		return err
+	}
+
+	err = unlockFile(file)
+	if err != nil {
+		_ = file.Close()
+
+		return wrapError(err)
+	}
+
+	err = file.Close()
+	if err != nil {
+		return wrapError(err)
+	}
+
+	return nil
+}
+
+// Append an entry to the locked log file, linked to the hash of the last line and signed if a key is configured.
+func (auditLog *AuditLog) appendEntry(file *os.File, entry Entry) error {
+	previous, err := lastLine(file)
+	if err != nil {
+		return err
+	}
+
+	if auditLog.hmacKey != nil {
+		var marked bool
+
+		marked, err = auditLog.hasKeyMarker(file)
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case !marked:
+			// Signatures are only required after a marker signed with the current key, so mark where signing
+			// starts when the key is first used, on a new log, one written without a key, or after rotation
+			previous, err = auditLog.writeLine(file, Entry{
+				Timestamp: entry.Timestamp,
+				Action:    ActionAuditKeyEnabled,
+				Detail:    "entries are signed from here",
+			}, previous)
+			if err != nil {
+				return err
+			}
+
+		case !auditLog.signedWithKey(previous):
+			// Linking to an unsigned line would vouch for whatever was appended without the key
+			return wrapError(errUnsignedLastEntry)
+		}
+	}
+
+	_, err = auditLog.writeLine(file, entry, previous)
+
+	return err
+}
+
+// Format an entry as a line following the previous one, signed if a key is configured.
+func (auditLog *AuditLog) formatLine(entry Entry, previous []byte) ([]byte, error) {
+	if len(previous) > 0 {
+		entry.PrevHash = hashLine(previous)
+	}
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return nil, wrapError(err)
+	}
+
+	if auditLog.hmacKey != nil {
+		line = append(line[:len(line)-1], signatureSuffix(auditLog.sign(line))...)
+	}
+
+	return line, nil
+}
+
+// Write an entry as a new line after the previous one, and return the line.
+func (auditLog *AuditLog) writeLine(file *os.File, entry Entry, previous []byte) ([]byte, error) {
+	line, err := auditLog.formatLine(entry, previous)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = file.Write(append(line, '\n'))
+	if err != nil {
+		return nil, wrapError(err)
+	}
+
+	return line, nil
+}
+
+// Longest entry that can be read, allowing for customers with long notes.
+const maxLineLength = 1024 * 1024
+
+// Entries reads every entry in the log, oldest first.
+func (auditLog *AuditLog) Entries() ([]Entry, error) {
+	entries := []Entry{}
+
+	if auditLog.filePath == "" {
+		return entries, nil
+	}
+
+	file, err := os.Open(auditLog.filePath)
+	if errors.Is(err, os.ErrNotExist) {
+		return entries, nil
+	}
+
+	if err != nil {
+		return nil, wrapError(err)
+	}
+
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(nil, maxLineLength)
+
+	for line := 1; scanner.Scan(); line++ {
+		var entry Entry
+
+		err = json.Unmarshal(scanner.Bytes(), &entry)
+		if err != nil {
+			return nil, wrapError(fmt.Errorf("line %d: %w", line, err))
+		}
+
+		entries = append(entries, entry)
+	}
+
+	err = scanner.Err()
+	if err != nil {
+		return nil, wrapError(err)
+	}
+
+	return entries, nil
+}
+
+// Check an entry's signature against the current key.
+func (auditLog *AuditLog) checkSignature(line []byte, entry Entry) error {
+	suffix := signatureSuffix(entry.HMAC)
+	if entry.HMAC == "" || !bytes.HasSuffix(line, suffix) {
+		return errMissingHMAC
+	}
+
+	unsigned := append(slices.Clone(line[:len(line)-len(suffix)]), '}')
+	if !hmac.Equal([]byte(auditLog.sign(unsigned)), []byte(entry.HMAC)) {
+		return errHMACMismatch
+	}
+
+	return nil
+}
+
+// Report whether a line is an entry signed with the current key.
+func (auditLog *AuditLog) signedWithKey(line []byte) bool {
+	var entry Entry
+
+	if len(line) == 0 || json.Unmarshal(line, &entry) != nil {
+		return false
+	}
+
+	return auditLog.checkSignature(line, entry) == nil
+}
+
+// Report whether the log holds a key enabled marker signed with the current key.
+func (auditLog *AuditLog) hasKeyMarker(file *os.File) (bool, error) {
+	_, err := file.Seek(0, io.SeekStart)
+	if err != nil {
+		return false, wrapError(err)
+	}
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(nil, maxLineLength)
+
+	for scanner.Scan() {
+		var entry Entry
+
+		// Lines that can't be parsed are left for Verify to report
+		if json.Unmarshal(scanner.Bytes(), &entry) != nil || entry.Action != ActionAuditKeyEnabled {
+			continue
+		}
+
+		if auditLog.checkSignature(scanner.Bytes(), entry) == nil {
+			return true, nil
+		}
+	}
+
+	err = scanner.Err()
+	if err != nil {
+		return false, wrapError(err)
+	}
+
+	return false, nil
+}
+
+// Verify walks the log, checking every entry links to the one before it. If a key is configured, every entry from
+// the first key enabled marker signed with it must also be signed with it. Earlier entries, written before the key
+// was enabled or signed with a previous key, are covered by the chain up to the signed marker.
+// Returns the number of entries and the hash of the last, which can be kept elsewhere to detect entries later
+// removed from the end, or an error identifying the first broken link.
+func (auditLog *AuditLog) Verify() (int, string, error) {
+	if auditLog.filePath == "" {
+		return 0, "", nil
+	}
+
+	file, err := os.Open(auditLog.filePath)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, "", nil
+	}
+
+	if err != nil {
+		return 0, "", wrapError(err)
+	}
+
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(nil, maxLineLength)
+
+	prevHash := ""
+	count := 0
+	signing := false
+
+	for ; scanner.Scan(); count++ {
+		line := scanner.Bytes()
+
+		var entry Entry
+
+		err = json.Unmarshal(line, &entry)
+		if err == nil && entry.PrevHash != prevHash {
+			err = errPrevHashMismatch
+		}
+
+		if err == nil && auditLog.hmacKey != nil {
+			err = auditLog.checkSignature(line, entry)
+
+			switch {
+			case err == nil && entry.Action == ActionAuditKeyEnabled:
+				signing = true
+			case !signing:
+				// Entries before the key was enabled are covered by the chain alone
+				err = nil
+			}
+		}
+
+		if err != nil {
+			return count, prevHash, wrapError(fmt.Errorf("%w at line %d: %w", errBrokenChain, count+1, err))
+		}
+
+		prevHash = hashLine(line)
+	}
+
+	err = scanner.Err()
+	if err != nil {
+		return count, prevHash, wrapError(err)
+	}
+
+	return count, prevHash, nil
+}
+
+// A Filter selects audit entries. Blank fields match every entry.
+type Filter struct {
+	ActorID string
+	Action  string // Matches the action, or any action under it, e.g. "customer" matches "customer.add"
+	From    time.Time
+	To      time.Time // Exclusive
+}
+
+// Matches reports whether the entry meets every condition of the filter.
+func (filter Filter) Matches(entry Entry) bool {
+	if filter.ActorID != "" && entry.ActorID != filter.ActorID {
+		return false
+	}
+
+	if filter.Action != "" && entry.Action != filter.Action && !strings.HasPrefix(entry.Action, filter.Action+".") {
+		return false
+	}
+
+	if !filter.From.IsZero() && entry.Timestamp.Before(filter.From) {
+		return false
+	}
+
+	if !filter.To.IsZero() && !entry.Timestamp.Before(filter.To) {
+		return false
+	}
+
+	return true
+}
+
+// Values longer than this are shortened when describing changes.
+const maxChangeLength = 40
+
+// Top-level keys whose values differ between before and after, in order.
+func changedKeys(before map[string]json.RawMessage, after map[string]json.RawMessage) []string {
+	keys := []string{}
+
+	for key := range before {
+		if !bytes.Equal(before[key], after[key]) {
+			keys = append(keys, key)
+		}
+	}
+
+	for key := range after {
+		if _, found := before[key]; !found {
+			keys = append(keys, key)
+		}
+	}
+
+	slices.Sort(keys)
+
+	return keys
+}
+
+// Marshal a value and split it into its top-level JSON fields.
+func topLevelFields(value any) (map[string]json.RawMessage, error) {
+	fields := map[string]json.RawMessage{}
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, wrapError(err)
+	}
+
+	err = json.Unmarshal(data, &fields)
+	if err != nil {
+		return nil, wrapError(err)
+	}
+
+	return fields, nil
+}
+
+// ChangedFields returns the names of the top-level JSON fields that differ between before and after,
+// for records whose values are too sensitive to keep in the log.
+func ChangedFields(before any, after any) ([]string, error) {
+	beforeFields, err := topLevelFields(before)
+	if err != nil {
+		return nil, err
+	}
+
+	afterFields, err := topLevelFields(after)
+	if err != nil {
+		return nil, err
+	}
+
+	return changedKeys(beforeFields, afterFields), nil
+}
+
+// Changes describes each top-level field that differs between the before and after values,
+// e.g. `role: "user" -> "admin"`, or names each changed field if only their names were recorded.
+// Entries without both values have no changes to describe.
+func (entry Entry) Changes() []string {
+	changes := []string{}
+
+	for _, field := range entry.Fields {
+		changes = append(changes, field+" changed")
+	}
+
+	before := map[string]json.RawMessage{}
+	after := map[string]json.RawMessage{}
+
+	if json.Unmarshal(entry.Before, &before) != nil || json.Unmarshal(entry.After, &after) != nil {
+		return changes
+	}
+
+	for _, key := range changedKeys(before, after) {
+		changes = append(changes, fmt.Sprintf("%s: %s -> %s", key, shorten(before[key]), shorten(after[key])))
+	}
+
+	return changes
+}
+
+func shorten(value json.RawMessage) string {
+	if value == nil {
+		return "-"
+	}
+
+	text := []rune(string(value))
+	if len(text) > maxChangeLength {
+		return string(text[:maxChangeLength-3]) + "..."
+	}
+
+	return string(text)
+}
@@ -0,0 +1,394 @@
+package audithandler
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+var (
+	testKey    = bytes.Repeat([]byte{1}, minHMACKeyLength)
+	rotatedKey = bytes.Repeat([]byte{2}, minHMACKeyLength)
+)
+
+// Record an entry with each key in turn, nil for no key, all to the same log file.
+func recordWithKeys(t *testing.T, path string, keys ...[]byte) {
+	t.Helper()
+
+	for _, key := range keys {
+		err := (&AuditLog{filePath: path, hmacKey: key}).Record(Entry{Action: ActionLogin}, nil, nil)
+		if err != nil {
+			t.Fatalf("Record() error = %v", err)
+		}
+	}
+}
+
+func readLines(t *testing.T, path string) [][]byte {
+	t.Helper()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return bytes.Split(bytes.TrimSuffix(data, []byte("\n")), []byte("\n"))
+}
+
+func writeLines(t *testing.T, path string, lines [][]byte) {
+	t.Helper()
+
+	err := os.WriteFile(path, append(bytes.Join(lines, []byte("\n")), '\n'), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Format a line as someone with the given key, or none, would write it after the last of the lines.
+func forgeLine(t *testing.T, key []byte, entry Entry, lines [][]byte) []byte {
+	t.Helper()
+
+	line, err := (&AuditLog{hmacKey: key}).formatLine(entry, lines[len(lines)-1])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return line
+}
+
+func TestVerifyIntactLogs(t *testing.T) {
+	tests := []struct {
+		name        string
+		keys        [][]byte // Key used for each entry recorded
+		verifyKey   []byte
+		wantEntries int
+	}{
+		{"no key", [][]byte{nil, nil, nil}, nil, 3},
+		{"signed from the start", [][]byte{testKey, testKey}, testKey, 3},
+		{"key enabled on an existing log", [][]byte{nil, nil, testKey, testKey}, testKey, 5},
+		{"key rotated", [][]byte{testKey, testKey, rotatedKey, rotatedKey}, rotatedKey, 6},
+		{"key removed", [][]byte{testKey, nil}, nil, 3},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "audit.jsonl")
+			recordWithKeys(t, path, test.keys...)
+
+			count, lastHash, err := (&AuditLog{filePath: path, hmacKey: test.verifyKey}).Verify()
+			if err != nil {
+				t.Fatalf("Verify() error = %v", err)
+			}
+
+			lines := readLines(t, path)
+			if count != test.wantEntries || lastHash != hashLine(lines[len(lines)-1]) {
+				t.Errorf("Verify() = %d, %s, want %d entries and the hash of the last", count, lastHash, test.wantEntries)
+			}
+		})
+	}
+}
+
+func TestVerifyTamperedLogs(t *testing.T) {
+	forged := Entry{Action: ActionCustomerRemove, TargetID: "forged"}
+
+	tests := []struct {
+		name          string
+		tamper        func(t *testing.T, lines [][]byte) [][]byte
+		wantErr       error
+		wantRecordErr error // Recording a further entry must not make the log verify
+	}{
+		{
+			name: "unsigned entry appended",
+			tamper: func(t *testing.T, lines [][]byte) [][]byte {
+				return append(lines, forgeLine(t, nil, forged, lines))
+			},
+			wantErr:       errMissingHMAC,
+			wantRecordErr: errUnsignedLastEntry,
+		},
+		{
+			name: "unsigned marker appended after an unsigned entry",
+			tamper: func(t *testing.T, lines [][]byte) [][]byte {
+				lines = append(lines, forgeLine(t, nil, forged, lines))
+
+				return append(lines, forgeLine(t, nil, Entry{Action: ActionAuditKeyEnabled}, lines))
+			},
+			wantErr:       errMissingHMAC,
+			wantRecordErr: errUnsignedLastEntry,
+		},
+		{
+			name: "marker signed with another key appended after an unsigned entry",
+			tamper: func(t *testing.T, lines [][]byte) [][]byte {
+				lines = append(lines, forgeLine(t, nil, forged, lines))
+
+				return append(lines, forgeLine(t, rotatedKey, Entry{Action: ActionAuditKeyEnabled}, lines))
+			},
+			wantErr:       errMissingHMAC,
+			wantRecordErr: errUnsignedLastEntry,
+		},
+		{
+			name: "last entry edited",
+			tamper: func(_ *testing.T, lines [][]byte) [][]byte {
+				lines[len(lines)-1] = bytes.Replace(lines[len(lines)-1], []byte(ActionLogin), []byte(ActionLogout), 1)
+
+				return lines
+			},
+			wantErr:       errHMACMismatch,
+			wantRecordErr: errUnsignedLastEntry,
+		},
+		{
+			name: "unsigned entry before the marker edited",
+			tamper: func(_ *testing.T, lines [][]byte) [][]byte {
+				lines[1] = bytes.Replace(lines[1], []byte(ActionLogin), []byte(ActionLogout), 1)
+
+				return lines
+			},
+			wantErr: errPrevHashMismatch,
+		},
+		{
+			name: "signed entry removed",
+			tamper: func(_ *testing.T, lines [][]byte) [][]byte {
+				return append(lines[:4], lines[5:]...)
+			},
+			wantErr: errPrevHashMismatch,
+		},
+		{
+			name: "entries after the marker rewritten without the key",
+			tamper: func(t *testing.T, lines [][]byte) [][]byte {
+				rewritten := lines[:3]
+				for range lines[3:] {
+					rewritten = append(rewritten, forgeLine(t, nil, forged, rewritten))
+				}
+
+				return rewritten
+			},
+			wantErr:       errMissingHMAC,
+			wantRecordErr: errUnsignedLastEntry,
+		},
+		{
+			name: "entries rewritten from before the marker without the key",
+			tamper: func(t *testing.T, lines [][]byte) [][]byte {
+				rewritten := lines[:1]
+				for range lines[1:] {
+					rewritten = append(rewritten, forgeLine(t, nil, forged, rewritten))
+				}
+
+				// Without the key, a marker can only be left out or forged
+				return append(rewritten, forgeLine(t, nil, Entry{Action: ActionAuditKeyEnabled}, rewritten))
+			},
+			wantErr: nil,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Two unsigned entries, then the marker and three signed entries
+			path := filepath.Join(t.TempDir(), "audit.jsonl")
+			recordWithKeys(t, path, nil, nil, testKey, testKey, testKey)
+
+			writeLines(t, path, test.tamper(t, readLines(t, path)))
+
+			auditLog := &AuditLog{filePath: path, hmacKey: testKey}
+
+			_, _, err := auditLog.Verify()
+			if test.wantErr == nil {
+				// Nothing signed with the key is left, so only the chain can be checked, and a fresh marker is
+				// written next. The hash of the last entry, kept elsewhere, is what detects this.
+				if err != nil {
+					t.Fatalf("Verify() error = %v, want only the chain checked", err)
+				}
+
+				return
+			}
+
+			if !errors.Is(err, test.wantErr) || !errors.Is(err, errBrokenChain) {
+				t.Fatalf("Verify() error = %v, want %v", err, test.wantErr)
+			}
+
+			err = auditLog.Record(Entry{Action: ActionLogin}, nil, nil)
+			if !errors.Is(err, test.wantRecordErr) {
+				t.Errorf("Record() error = %v, want %v", err, test.wantRecordErr)
+			}
+
+			_, _, err = auditLog.Verify()
+			if !errors.Is(err, test.wantErr) {
+				t.Errorf("Verify() after Record() error = %v, want %v", err, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestVerifyTruncatedLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	recordWithKeys(t, path, testKey, testKey, testKey)
+
+	auditLog := &AuditLog{filePath: path, hmacKey: testKey}
+
+	count, lastHash, err := auditLog.Verify()
+	if err != nil {
+		t.Fatalf("Verify() error = %v", err)
+	}
+
+	lines := readLines(t, path)
+
+	// Removing entries from the end leaves a valid chain, detected by comparing with the last hash kept elsewhere
+	writeLines(t, path, lines[:len(lines)-1])
+
+	truncatedCount, truncatedHash, err := auditLog.Verify()
+	if err != nil || truncatedCount != count-1 || truncatedHash == lastHash {
+		t.Errorf("Verify() = %d, %s, %v, want %d entries with a different last hash", truncatedCount, truncatedHash,
+			err, count-1)
+	}
+
+	// A line cut short can't be parsed
+	lines[len(lines)-1] = lines[len(lines)-1][:len(lines[len(lines)-1])/2]
+	writeLines(t, path, lines)
+
+	_, _, err = auditLog.Verify()
+	if !errors.Is(err, errBrokenChain) {
+		t.Errorf("Verify() error = %v, want %v", err, errBrokenChain)
+	}
+}
+
+func TestKeyRotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	recordWithKeys(t, path, testKey, testKey, rotatedKey, rotatedKey)
+
+	markers := 0
+
+	for _, line := range readLines(t, path) {
+		if bytes.Contains(line, []byte(ActionAuditKeyEnabled)) {
+			markers++
+		}
+	}
+
+	if markers != 2 {
+		t.Errorf("log has %d key enabled markers, want one for each key", markers)
+	}
+
+	// Entries signed with the new key can't be checked with the old one
+	_, _, err := (&AuditLog{filePath: path, hmacKey: testKey}).Verify()
+	if !errors.Is(err, errHMACMismatch) {
+		t.Errorf("Verify() with the old key error = %v, want %v", err, errHMACMismatch)
+	}
+
+	// Going back to an earlier key isn't supported, as its marker is already in the log
+	err = (&AuditLog{filePath: path, hmacKey: testKey}).Record(Entry{Action: ActionLogin}, nil, nil)
+	if !errors.Is(err, errUnsignedLastEntry) {
+		t.Errorf("Record() with the old key error = %v, want %v", err, errUnsignedLastEntry)
+	}
+}
+
+func TestFilterMatches(t *testing.T) {
+	now := time.Now()
+	entry := Entry{ActorID: "user-1", Action: ActionCustomerAdd, Timestamp: now}
+
+	tests := []struct {
+		name   string
+		filter Filter
+		want   bool
+	}{
+		{"empty filter", Filter{}, true},
+		{"actor", Filter{ActorID: "user-1"}, true},
+		{"other actor", Filter{ActorID: "user-2"}, false},
+		{"exact action", Filter{Action: ActionCustomerAdd}, true},
+		{"action group", Filter{Action: "customer"}, true},
+		{"other action", Filter{Action: "customer.remove"}, false},
+		{"action prefix that isn't a group", Filter{Action: "cust"}, false},
+		{"from is inclusive", Filter{From: now}, true},
+		{"from after", Filter{From: now.Add(time.Second)}, false},
+		{"to is exclusive", Filter{To: now}, false},
+		{"to after", Filter{To: now.Add(time.Second)}, true},
+		{
+			"every condition",
+			Filter{ActorID: "user-1", Action: "customer", From: now.Add(-time.Hour), To: now.Add(time.Hour)},
+			true,
+		},
+		{"one condition fails", Filter{ActorID: "user-1", Action: "user"}, false},
+	}
+
+	for _, test := range tests {
+		if got := test.filter.Matches(entry); got != test.want {
+			t.Errorf("%s: Matches() = %t, want %t", test.name, got, test.want)
+		}
+	}
+}
+
+func TestChangedFields(t *testing.T) {
+	type address struct {
+		Town string `json:"town"`
+	}
+
+	type record struct {
+		Name    string  `json:"name"`
+		Notes   string  `json:"notes,omitempty"`
+		Address address `json:"address"`
+	}
+
+	original := record{Name: "Acme", Notes: "Ring first", Address: address{Town: "Leeds"}}
+
+	tests := []struct {
+		name   string
+		before any
+		after  any
+		want   []string
+	}{
+		{"unchanged", original, original, []string{}},
+		{
+			"changed",
+			original,
+			record{Name: "Acme Ltd", Notes: "Ring first", Address: address{Town: "Leeds"}},
+			[]string{"name"},
+		},
+		{"removed", original, record{Name: "Acme", Address: address{Town: "Leeds"}}, []string{"notes"}},
+		{"added", record{Name: "Acme"}, record{Name: "Acme", Notes: "Ring first"}, []string{"notes"}},
+		{
+			"nested change names the top-level field",
+			original,
+			record{Name: "Acme", Notes: "Ring first", Address: address{Town: "York"}},
+			[]string{"address"},
+		},
+		{"no before", nil, original, []string{"address", "name", "notes"}},
+		{"no after", original, nil, []string{"address", "name", "notes"}},
+	}
+
+	for _, test := range tests {
+		got, err := ChangedFields(test.before, test.after)
+		if err != nil || !slices.Equal(got, test.want) {
+			t.Errorf("%s: ChangedFields() = %q, %v, want %q", test.name, got, err, test.want)
+		}
+	}
+
+	_, err := ChangedFields(original, make(chan int))
+	if err == nil {
+		t.Error("ChangedFields() of a value that can't be marshalled succeeded")
+	}
+}
+
+func TestEntryChanges(t *testing.T) {
+	entry := Entry{
+		Fields: []string{"address"},
+		Before: []byte(`{"name":"Acme","role":"user"}`),
+		After:  []byte(`{"name":"Acme","role":"admin","notes":"` + strings.Repeat("x", maxChangeLength) + `"}`),
+	}
+
+	want := []string{
+		"address changed",
+		`notes: - -> "` + strings.Repeat("x", maxChangeLength-4) + "...",
+		`role: "user" -> "admin"`,
+	}
+
+	if got := entry.Changes(); !slices.Equal(got, want) {
+		t.Errorf("Changes() = %q, want %q", got, want)
+	}
+
+	// Entries without both values only describe the fields named
+	entry.Before = nil
+
+	if got := entry.Changes(); !slices.Equal(got, want[:1]) {
+		t.Errorf("Changes() without before = %q, want %q", got, want[:1])
+	}
+}
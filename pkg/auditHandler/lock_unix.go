@@ -0,0 +1,18 @@
+//go:build unix
+
+package audithandler
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// Take an exclusive lock on the file, waiting for any other process or handle holding it.
+func lockFile(file *os.File) error {
+	return unix.Flock(int(file.Fd()), unix.LOCK_EX)
+}
+
+func unlockFile(file *os.File) error {
+	return unix.Flock(int(file.Fd()), unix.LOCK_UN)
+}
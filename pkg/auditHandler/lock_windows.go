@@ -0,0 +1,25 @@
+//go:build windows
+
+package audithandler
+
+import (
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+// Byte locked by lockFile, far beyond the end of the log. Windows locks are mandatory,
+// so locking the log's contents would stop other processes reading it.
+const lockOffsetHigh = 0x7FFFFFFF
+
+// Take an exclusive lock on the file, waiting for any other process or handle holding it.
+func lockFile(file *os.File) error {
+	return windows.LockFileEx(
+		windows.Handle(file.Fd()), windows.LOCKFILE_EXCLUSIVE_LOCK, 0, 1, 0,
+		&windows.Overlapped{OffsetHigh: lockOffsetHigh},
+	)
+}
+
+func unlockFile(file *os.File) error {
+	return windows.UnlockFileEx(windows.Handle(file.Fd()), 0, 1, 0, &windows.Overlapped{OffsetHigh: lockOffsetHigh})
+}
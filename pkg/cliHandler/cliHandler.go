@@ -13,7 +13,8 @@ import (
 )
 
 type CLIHandler struct {
-	reader *bufio.Reader
+	reader    *bufio.Reader
+	inputHook func() error
 }
 
 func wrapError(err error) error {
@@ -30,6 +31,20 @@ func New() *CLIHandler {
 	}
 }
 
+// SetInputHook sets a function run after every input is read. If it returns an error, the input is discarded
+// and the error returned instead.
+func (cli *CLIHandler) SetInputHook(hook func() error) {
+	cli.inputHook = hook
+}
+
+func (cli *CLIHandler) runInputHook() error {
+	if cli.inputHook == nil {
+		return nil
+	}
+
+	return cli.inputHook()
+}
+
 func (cli *CLIHandler) WriteOutput(output string) {
 	fmt.Println(output)
 }
@@ -46,6 +61,11 @@ func (cli *CLIHandler) GetUserInput(prompt string) (string, error) {
 	// Trim new line an carriage returns
 	input := strings.ReplaceAll(strings.ReplaceAll(text, "\n", ""), "\r", "")
 
+	err = cli.runInputHook()
+	if err != nil {
+		return "", err
+	}
+
 	return input, nil
 }
 
@@ -84,9 +104,27 @@ func (cli *CLIHandler) GetSensitiveInput(prompt string) (string, error) {
 	// Stop looking for ^C on the channel.
 	signal.Stop(interuptChan)
 
+	err = cli.runInputHook()
+	if err != nil {
+		return "", err
+	}
+
 	return string(sensitiveString), nil
 }
 
+// Width assumed when output isn't a terminal, or its size can't be read.
+const defaultTerminalWidth = 80
+
+// TerminalWidth returns the width of the terminal in characters.
+func (cli *CLIHandler) TerminalWidth() int {
+	width, _, err := term.GetSize(int(os.Stdout.Fd()))
+	if err != nil || width <= 0 {
+		return defaultTerminalWidth
+	}
+
+	return width
+}
+
 func (cli *CLIHandler) ClearTerminal() {
 	clearMap := make(map[string]func()) // Initialize it
 	clearMap["linux"] = func() {
@@ -0,0 +1,177 @@
+package commandhandler
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+	audithandler "work-mini-project/pkg/auditHandler"
+	crmhandler "work-mini-project/pkg/crmHandler"
+
+	"github.com/jedib0t/go-pretty/v6/table"
+)
+
+// Most recent matching entries shown by the audit log viewer.
+const auditPageSize = 50
+
+var errUnknownAuditFilter = errors.New("error, unknown audit log filter")
+
+var errInvalidAuditDate = errors.New("error, dates must be in YYYY-MM-DD format")
+
+// Parse filters such as "user:admin action:customer from:2024-01-01 to:2024-01-31" into an audit log filter.
+// Dates are inclusive.
+func (ch *CommandHandler) parseAuditFilter(input string) (audithandler.Filter, error) {
+	filter := audithandler.Filter{}
+
+	for _, field := range strings.Fields(input) {
+		key, value, _ := strings.Cut(field, ":")
+
+		switch strings.ToLower(key) {
+		case "user":
+			// Deleted and purged users can still be found in the log, so search every stored user
+			index := slices.IndexFunc(ch.crmHandler.Users, func(user crmhandler.User) bool {
+				return strings.EqualFold(user.Username, value)
+			})
+			if index == -1 {
+				return filter, wrapError(fmt.Errorf("%w: user:%s", errUnknownAuditFilter, value))
+			}
+
+			filter.ActorID = ch.crmHandler.Users[index].ID
+
+		case "action":
+			filter.Action = value
+
+		case "from", "to":
+			date, err := time.ParseInLocation(time.DateOnly, value, time.Local)
+			if err != nil {
+				return filter, errInvalidAuditDate
+			}
+
+			if key == "from" {
+				filter.From = date
+			} else {
+				filter.To = date.AddDate(0, 0, 1)
+			}
+
+		default:
+			return filter, wrapError(fmt.Errorf("%w: %s", errUnknownAuditFilter, field))
+		}
+	}
+
+	return filter, nil
+}
+
+// Name the target of an audit entry. Delivery entries only record the customer's ID, so the name is looked up,
+// leaving only the ID once the customer has been purged.
+func (ch *CommandHandler) auditTargetName(entry audithandler.Entry) string {
+	if entry.TargetName != "" {
+		return entry.TargetName
+	}
+
+	if strings.HasPrefix(entry.Action, "delivery.") {
+		if customer, err := ch.customerHandler.GetCustomerByID(entry.TargetID); err == nil {
+			return customer.Name
+		}
+	}
+
+	return entry.TargetID
+}
+
+// Render the most recent matching audit entries, newest first.
+func (ch *CommandHandler) renderAuditLog(entries []audithandler.Entry, filter audithandler.Filter) string {
+	matching := slices.DeleteFunc(slices.Clone(entries), func(entry audithandler.Entry) bool {
+		return !filter.Matches(entry)
+	})
+
+	slices.Reverse(matching)
+
+	auditTable := table.NewWriter()
+	auditTable.AppendHeader(table.Row{"Time", "User", "Action", "Target", "Details"})
+
+	for _, entry := range matching[:min(len(matching), auditPageSize)] {
+		actor := "-"
+		if entry.ActorID != "" {
+			actor = "unknown user"
+			if user, err := ch.crmHandler.GetUserByID(entry.ActorID); err == nil {
+				actor = user.Username
+			}
+		}
+
+		target := ch.auditTargetName(entry)
+
+		details := append([]string{}, entry.Changes()...)
+		if entry.Detail != "" {
+			details = append([]string{entry.Detail}, details...)
+		}
+
+		auditTable.AppendRow(table.Row{
+			entry.Timestamp.Local().Format(time.DateTime), actor, entry.Action, target, strings.Join(details, "\n"),
+		})
+	}
+
+	return fmt.Sprintf("%s\nShowing %d of %d matching entries",
+		auditTable.Render(), min(len(matching), auditPageSize), len(matching))
+}
+
+// Describe whether the audit log's hash chain is intact, or where it is first broken.
+func verifyAuditLog(auditLog *audithandler.AuditLog) string {
+	count, lastHash, err := auditLog.Verify()
+	if err != nil {
+		return err.Error()
+	}
+
+	return fmt.Sprintf("Audit log verified: %d entries intact, last hash %s", count, lastHash)
+}
+
+// Show the audit log, narrowed by filters until the user cancels.
+func (ch *CommandHandler) handleViewAuditLog() error {
+	auditLog, err := audithandler.New(ch.config)
+	if err != nil {
+		return wrapError(err)
+	}
+
+	filter := audithandler.Filter{}
+
+	for {
+		entries, err := auditLog.Entries()
+		if err != nil {
+			return wrapError(err)
+		}
+
+		err = ch.crmHandler.Reload()
+		if err != nil {
+			return wrapError(err)
+		}
+
+		ch.cliHandler.ClearTerminal()
+		ch.cliHandler.WriteOutput("Audit Log\n\n" + ch.renderAuditLog(entries, filter))
+
+		input, err := ch.cliHandler.GetUserInput(auditFilterText)
+		if err != nil {
+			return wrapError(err)
+		}
+
+		if ch.checkForKeywords(input) {
+			return nil
+		}
+
+		if strings.EqualFold(strings.TrimSpace(input), "verify") {
+			ch.cliHandler.WriteOutput(verifyAuditLog(auditLog))
+			ch.anyKeyToContinue()
+
+			continue
+		}
+
+		// Keep showing the previous filter if the new one is invalid
+		newFilter, err := ch.parseAuditFilter(input)
+		if err != nil {
+			ch.cliHandler.WriteOutput(err.Error())
+			ch.anyKeyToContinue()
+
+			continue
+		}
+
+		filter = newFilter
+	}
+}
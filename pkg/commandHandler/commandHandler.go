@@ -11,6 +11,8 @@ import (
 	"work-mini-project/pkg/configuration"
 	crmhandler "work-mini-project/pkg/crmHandler"
 	customerhandler "work-mini-project/pkg/customerHandler"
+	deliveryhandler "work-mini-project/pkg/deliveryHandler"
+	geohandler "work-mini-project/pkg/geoHandler"
 	transporthandler "work-mini-project/pkg/transportHandler"
 
 	"github.com/jedib0t/go-pretty/v6/table"
@@ -22,6 +24,7 @@ type CommandHandler struct {
 	crmHandler       *crmhandler.CRMHandler
 	customerHandler  *customerhandler.CustomerHandler
 	transportHandler *transporthandler.TransportHandler
+	deliveryHandler  *deliveryhandler.DeliveryHandler
 }
 
 func errUnrecognisedCommand(command string) error {
@@ -33,22 +36,27 @@ var errInvalidSelection = errors.New("error, invalid selection, please try again
 
 var errNoSelfDelete = errors.New("error, cannot delete own user, please try again")
 
+var errNoSelfReset = errors.New("error, use change password to change own password, please try again")
+
 var errNoSelfRoleEdit = errors.New("error, cannot modify own users role, please try again")
 
+var errNoSelfTwoFactorReset = errors.New("error, cannot reset own two-factor authentication, please try again")
+
+var errImportChanged = errors.New("error, customers changed during import, no customers were added. please try again")
+
 var errKeywordEscape = errors.New("") // keyword escape, shouldn't show to user
 
 func wrapError(err error) error {
 	return fmt.Errorf("commandHandler: %w", err)
 }
 
-const AdminRole = "admin"
-
 func New(
 	config *configuration.Config,
 	cliHandler *clihandler.CLIHandler,
 	crmHandler *crmhandler.CRMHandler,
 	customerHandler *customerhandler.CustomerHandler,
 	transportHandler *transporthandler.TransportHandler,
+	deliveryHandler *deliveryhandler.DeliveryHandler,
 ) *CommandHandler {
 	return &CommandHandler{
 		config:           config,
@@ -56,6 +64,7 @@ func New(
 		crmHandler:       crmHandler,
 		customerHandler:  customerHandler,
 		transportHandler: transportHandler,
+		deliveryHandler:  deliveryHandler,
 	}
 }
 
@@ -76,7 +85,10 @@ func (ch *CommandHandler) checkForKeywords(command string) bool {
 		return true
 
 	case "logout":
-		ch.crmHandler.LoggedInUser = nil
+		err := ch.crmHandler.Logout()
+		if err != nil {
+			ch.cliHandler.WriteOutput(wrapError(err).Error())
+		}
 
 		return true
 
@@ -96,7 +108,7 @@ func (ch *CommandHandler) checkForKeywords(command string) bool {
 
 func (ch *CommandHandler) initialCommands() error {
 	// If not logged in, entry screen
-	if ch.crmHandler.LoggedInUser == nil {
+	if ch.crmHandler.LoggedInUser() == nil {
 		selection, err := ch.cliHandler.GetUserInput(introText)
 		if err != nil {
 			return wrapError(err)
@@ -145,158 +157,349 @@ func (ch *CommandHandler) initialCommands() error {
 }
 
 func (ch *CommandHandler) postLoginCommands() error {
-	prompt := postLoginText
-	if ch.crmHandler.LoggedInUser.Role == AdminRole {
-		prompt += adminPostLoginText
-	}
+	return ch.runMenu(selectFunctionText, []menuItem{
+		{
+			label:       "Calculate Journey",
+			permissions: []crmhandler.Permission{crmhandler.PermissionQuotesCreate},
+			action:      ch.handleCalculateDelivery,
+		},
+		{
+			label:       "View Customer",
+			permissions: []crmhandler.Permission{crmhandler.PermissionCustomersRead},
+			action:      ch.handleViewCustomer,
+		},
+		{label: "Manage Customers", submenu: ch.customerMenu()},
+		{label: "Manage Users", submenu: ch.userMenu()},
+		{
+			label:   "Recycle Bin",
+			submenu: ch.recycleBinMenu(),
+			title: fmt.Sprintf("Deleted records can be purged %d day(s) after deletion.\n%s",
+				ch.config.RecycleBin.RetentionDays, selectActionText),
+		},
+		{
+			label:       "Audit Log",
+			permissions: []crmhandler.Permission{crmhandler.PermissionAuditView},
+			action:      ch.handleViewAuditLog,
+		},
+		{label: "Change Password", action: ch.handleChangePassword},
+		{label: "Two-Factor Authentication", action: ch.handleTwoFactor},
+	})
+}
 
-	selection, err := ch.cliHandler.GetUserInput(prompt)
+func (ch *CommandHandler) customerSelectMenu() (customerhandler.Customer, error) {
+	// Make sure customers changed by other users are listed
+	err := ch.customerHandler.Reload()
 	if err != nil {
-		return wrapError(err)
+		return customerhandler.Customer{}, wrapError(err)
 	}
 
-	if ch.checkForKeywords(selection) {
-		return nil
+	menu := newSelectMenu("Select Customer:", map[string]selectFilter[customerhandler.Customer]{
+		"town": func(customer customerhandler.Customer, town string) bool {
+			return strings.EqualFold(customer.Address.Town, town)
+		},
+		"postcode": func(customer customerhandler.Customer, postcode string) bool {
+			return strings.HasPrefix(strings.ToLower(customer.Address.Postcode), strings.ToLower(postcode))
+		},
+		"region": func(customer customerhandler.Customer, region string) bool {
+			return strings.EqualFold(ch.customerHandler.RegionOf(customer), region)
+		},
+		"tag": func(customer customerhandler.Customer, tag string) bool {
+			return customer.HasTag(tag)
+		},
+	})
+
+	for _, customer := range ch.customerHandler.ActiveCustomers() {
+		menu.addOption(customer.Name, customer.Name, customer)
 	}
 
-	switch selection {
-	case "1": // Calculate Journey
-		return ch.handleCalculateDelivery()
+	return selectFromMenu(ch, menu)
+}
 
-	case "2": // Manage Customers
-		if ch.crmHandler.LoggedInUser.Role != AdminRole {
-			return nil
-		}
+func (ch *CommandHandler) userSelectMenu() (crmhandler.User, error) {
+	// Make sure users changed by other users are listed
+	err := ch.crmHandler.Reload()
+	if err != nil {
+		return crmhandler.User{}, wrapError(err)
+	}
 
-		return ch.handleManageCustomers()
+	menu := newSelectMenu("Select User (username (role)):", map[string]selectFilter[crmhandler.User]{
+		"role": func(user crmhandler.User, role string) bool {
+			return strings.EqualFold(user.Role, role)
+		},
+	})
 
-	case "3": // Manage Users
-		if ch.crmHandler.LoggedInUser.Role != AdminRole {
-			return nil
+	for _, user := range ch.crmHandler.ActiveUsers() {
+		label := fmt.Sprintf("%s (%s)", user.Username, user.Role)
+		if user.IsLocked() {
+			label += " - locked until " + user.LockedUntil.Format(time.DateTime)
 		}
 
-		return ch.handleManageUsers()
-
-	default:
-		ch.cliHandler.ClearTerminal()
-
-		return errUnrecognisedCommand(selection)
+		menu.addOption(user.Username, label, user)
 	}
+
+	return selectFromMenu(ch, menu)
 }
 
-func (ch *CommandHandler) customerSelectMenu() (customerhandler.Customer, error) {
-	ch.cliHandler.WriteOutput("Select Customer:\n")
+func (ch *CommandHandler) roleSelectMenu() (crmhandler.AccountRole, error) {
+	roles := ch.crmHandler.RoleNames()
 
-	customerList := ""
-	for i, customer := range ch.customerHandler.Customers {
-		customerList += fmt.Sprintf("%d - %s\n", i+1, customer.Name)
+	prompt := "Select New Role:\n"
+	for i, role := range roles {
+		prompt += fmt.Sprintf("\n%d - %s", i+1, role)
 	}
 
-	selection, err := ch.cliHandler.GetUserInput(customerList)
+	selection, err := ch.cliHandler.GetUserInput(prompt)
 	if err != nil {
-		return customerhandler.Customer{}, wrapError(err)
+		return crmhandler.USER, wrapError(err)
 	}
 
 	if ch.checkForKeywords(selection) {
-		return customerhandler.Customer{}, errKeywordEscape
+		return crmhandler.USER, errKeywordEscape
+	}
+
+	index, err := strconv.Atoi(selection)
+	if err != nil || index < 1 || index > len(roles) {
+		return crmhandler.USER, errUnrecognisedCommand(selection)
 	}
 
-	index, err := strconv.ParseInt(selection, 10, 0)
+	return roles[index-1], nil
+}
+
+func (ch *CommandHandler) handleCalculateDelivery() error {
+	ch.cliHandler.ClearTerminal()
+
+	customer, err := ch.customerSelectMenu()
 	if err != nil {
-		return customerhandler.Customer{}, wrapError(err)
+		return err
 	}
 
-	if index > int64(len(ch.customerHandler.Customers)) {
-		return customerhandler.Customer{}, errInvalidSelection
+	trips := ch.transportHandler.CalculateCosts(customer)
+
+	methodTable := table.NewWriter()
+
+	// Show list prices alongside agreed prices for customers with a pricing agreement, to users who may view pricing
+	showPricing := customer.Pricing != nil && ch.can(crmhandler.PermissionPricingView)
+
+	if showPricing {
+		methodTable.AppendHeader(table.Row{"#", "Transport Method", "Time Taken", "List Price", "Agreed Price"})
+	} else {
+		methodTable.AppendHeader(table.Row{"#", "Transport Method", "Time Taken", "Cost"})
 	}
 
-	customer := ch.customerHandler.Customers[index-1]
+	for i, trip := range trips {
+		row := table.Row{
+			i + 1,
+			trip.Method,
+			formatDuration(trip.Duration),
+		}
 
-	return customer, nil
+		if showPricing {
+			row = append(row, fmt.Sprintf("£%.2f", trip.ListCost))
+		}
+
+		methodTable.AppendRow(append(row, fmt.Sprintf("£%.2f", trip.Cost)))
+	}
+
+	outputMessage := "Costs and durations for all available transport methods: \n\n"
+
+	ch.cliHandler.WriteOutput(outputMessage)
+	ch.cliHandler.WriteOutput(methodTable.Render())
+
+	err = ch.handleQuoteActions(customer, trips)
+	if err != nil {
+		return err
+	}
+
+	ch.cliHandler.ClearTerminal()
+
+	return nil
 }
 
-func (ch *CommandHandler) userSelectMenu() (crmhandler.User, error) {
-	ch.cliHandler.WriteOutput("Select User (username (role)):\n")
+// After showing a quote, offer to book one of the trips as a delivery, show a trip's route on the grid map,
+// or export the routes of every trip to an image.
+// A blank input returns to the menu.
+func (ch *CommandHandler) handleQuoteActions(
+	customer customerhandler.Customer,
+	trips []*transporthandler.TripDetails,
+) error {
+	for {
+		prompt := quoteActionsText
+		if ch.can(crmhandler.PermissionDeliveriesBook) {
+			prompt = bookDeliveryText + prompt
+		}
+
+		selection, err := ch.cliHandler.GetUserInput(prompt)
+		if err != nil {
+			return wrapError(err)
+		}
+
+		selection = strings.TrimSpace(selection)
+		if selection == "" || ch.checkForKeywords(selection) {
+			return nil
+		}
+
+		showMap := false
+
+		command, argument, _ := strings.Cut(selection, " ")
+
+		switch strings.ToLower(command) {
+		case "map":
+			showMap, selection = true, strings.TrimSpace(argument)
 
-	userList := ""
-	for i, user := range ch.crmHandler.Users {
-		userList += fmt.Sprintf("%d - %s (%s)\n", i+1, user.Username, user.Role)
+		case "export":
+			ch.exportQuoteMap(customer, trips, strings.TrimSpace(argument))
+
+			continue
+		}
+
+		if showMap && selection == "" {
+			ch.showMap(customer, nil)
+
+			continue
+		}
+
+		index, err := strconv.Atoi(selection)
+		if err != nil || index < 1 || index > len(trips) {
+			ch.cliHandler.WriteOutput(errInvalidSelection.Error())
+
+			continue
+		}
+
+		if showMap {
+			ch.showMap(customer, trips[index-1])
+
+			continue
+		}
+
+		if !ch.can(crmhandler.PermissionDeliveriesBook) {
+			ch.cliHandler.WriteOutput(errPermissionDenied.Error())
+
+			continue
+		}
+
+		return ch.bookDelivery(customer, trips[index-1])
 	}
+}
+
+// Prompt suffix shown when editing an existing value, which is kept if the input is left blank.
+func keepValueHint(value any) string {
+	return fmt.Sprintf(" (leave blank to keep \"%v\")", value)
+}
 
-	selection, err := ch.cliHandler.GetUserInput(userList)
+func (ch *CommandHandler) handleViewCustomer() error {
+	ch.cliHandler.ClearTerminal()
+
+	customer, err := ch.customerSelectMenu()
 	if err != nil {
-		return crmhandler.User{}, wrapError(err)
+		return err
 	}
 
-	if ch.checkForKeywords(selection) {
-		return crmhandler.User{}, errKeywordEscape
+	region := ch.customerHandler.RegionOf(customer)
+	if customer.Region == "" {
+		region += " (from grid location)"
 	}
 
-	index, err := strconv.ParseInt(selection, 10, 0)
+	deliveries, err := ch.deliveryHandler.ForCustomer(customer.ID)
 	if err != nil {
-		return crmhandler.User{}, wrapError(err)
+		return wrapError(err)
 	}
 
-	if index > int64(len(ch.crmHandler.Users)) {
-		return crmhandler.User{}, errInvalidSelection
-	}
+	ch.cliHandler.ClearTerminal()
+	ch.cliHandler.WriteOutput(renderCustomerDetails(customer, region, ch.can(crmhandler.PermissionPricingView)))
+	ch.cliHandler.WriteOutput("\n" + renderDeliveryHistory(deliveries))
 
-	user := ch.crmHandler.Users[index-1]
+	ch.anyKeyToContinue()
 
-	return user, nil
-}
+	ch.cliHandler.ClearTerminal()
 
-func (ch *CommandHandler) roleSelectMenu() (crmhandler.AccountRole, error) {
-	prompt := `Select New Role:
-1 - User
-2 - Admin`
+	return nil
+}
 
-	selection, err := ch.cliHandler.GetUserInput(prompt)
-	if err != nil {
-		return crmhandler.USER, wrapError(err)
+func renderCustomerDetails(customer customerhandler.Customer, region string, showPricing bool) string {
+	detailsTable := table.NewWriter()
+	detailsTable.SetTitle(customer.Name)
+
+	detailsTable.AppendRows([]table.Row{
+		{"Account Number", customer.AccountNumber},
+		{"Grid Location", fmt.Sprintf("%d, %d", customer.GridX, customer.GridY)},
+		{"Coordinates", coordinatesText(customer.Coordinates)},
+		{"Address", strings.Join(customer.Address.Lines(), "\n")},
+		{"Region", region},
+		{"Tags", strings.Join(customer.Tags, ", ")},
+		{"Delivery Instructions", customer.DeliveryInstructions},
+		{"Notes", customer.Notes},
+	})
+
+	output := detailsTable.Render()
+
+	if customer.Pricing != nil && showPricing {
+		output += "\n\n" + renderPricingAgreement(customer.Pricing)
 	}
 
-	if ch.checkForKeywords(selection) {
-		return crmhandler.USER, errKeywordEscape
-	}
+	if len(customer.Contacts) > 0 {
+		contactsTable := table.NewWriter()
+		contactsTable.SetTitle("Contacts")
+		contactsTable.AppendHeader(table.Row{"Name", "Phone", "Email"})
 
-	switch selection {
-	case "1":
-		return crmhandler.USER, nil
+		for _, contact := range customer.Contacts {
+			contactsTable.AppendRow(table.Row{contact.Name, contact.Phone, contact.Email})
+		}
 
-	case "2":
-		return crmhandler.ADMIN, nil
+		output += "\n\n" + contactsTable.Render()
+	}
 
-	default:
-		return crmhandler.USER, errUnrecognisedCommand(selection)
+	return output
+}
+
+func coordinatesText(coordinates *geohandler.Coordinates) string {
+	if coordinates == nil {
+		return ""
 	}
+
+	return coordinates.String()
 }
 
-func (ch *CommandHandler) handleCalculateDelivery() error {
+// Show the customers in each region and with each tag.
+func (ch *CommandHandler) handleCustomerGroups() error {
+	err := ch.customerHandler.Reload()
+	if err != nil {
+		return wrapError(err)
+	}
+
 	ch.cliHandler.ClearTerminal()
 
-	customer, err := ch.customerSelectMenu()
-	if err != nil {
-		return err
+	customerNames := func(customers []customerhandler.Customer) string {
+		names := make([]string, len(customers))
+		for i, customer := range customers {
+			names[i] = customer.Name
+		}
+
+		return strings.Join(names, "\n")
 	}
 
-	trips := ch.transportHandler.CalculateCosts(customer)
+	regionTable := table.NewWriter()
+	regionTable.AppendHeader(table.Row{"Region", "Count", "Customers"})
 
-	methodTable := table.NewWriter()
-	methodTable.AppendHeader(table.Row{"Transport Method", "Time Taken", "Cost"})
+	regions, regionGroups := ch.customerHandler.GroupByRegion()
+	for _, region := range regions {
+		regionTable.AppendRow(table.Row{region, len(regionGroups[region]), customerNames(regionGroups[region])})
+		regionTable.AppendSeparator()
+	}
 
-	for _, trip := range trips {
-		methodTable.AppendRow(table.Row{
-			trip.Method,
-			time.Unix(0, 0).UTC().Add(trip.Duration).Format("15:04:05"),
-			fmt.Sprintf("£%.2f", trip.Cost),
-		})
+	tagTable := table.NewWriter()
+	tagTable.AppendHeader(table.Row{"Tag", "Count", "Customers"})
+
+	tags, tagGroups := ch.customerHandler.GroupByTag()
+	for _, tag := range tags {
+		tagTable.AppendRow(table.Row{tag, len(tagGroups[tag]), customerNames(tagGroups[tag])})
+		tagTable.AppendSeparator()
 	}
 
-	outputMessage := "Costs and durations for all available transport methods: \n\n"
+	ch.cliHandler.WriteOutput(regionTable.Render())
 
-	ch.cliHandler.WriteOutput(outputMessage)
-	ch.cliHandler.WriteOutput(methodTable.Render())
+	if len(tags) > 0 {
+		ch.cliHandler.WriteOutput("\n" + tagTable.Render())
+	}
 
 	ch.anyKeyToContinue()
 
@@ -305,8 +508,13 @@ func (ch *CommandHandler) handleCalculateDelivery() error {
 	return nil
 }
 
-func (ch *CommandHandler) getCustomerName() (string, error) {
+// Prompt for a customer name, which must not be used by another customer.
+// If editing an existing customer, a blank input keeps its current name.
+func (ch *CommandHandler) getCustomerName(existing *customerhandler.Customer) (string, error) {
 	prompt := "\nPlease provide a customer name:"
+	if existing != nil {
+		prompt = "\nPlease provide a customer name" + keepValueHint(existing.Name) + ":"
+	}
 
 	for {
 		customerName, err := ch.cliHandler.GetUserInput(prompt)
@@ -318,10 +526,20 @@ func (ch *CommandHandler) getCustomerName() (string, error) {
 			return "", errKeywordEscape
 		}
 
-		_, err = ch.customerHandler.GetCustomer(customerName)
+		if existing != nil && customerName == "" {
+			return existing.Name, nil
+		}
+
+		if customerName == "" {
+			prompt = "\nCustomer name cannot be blank, please try again:"
+
+			continue
+		}
+
+		matchingCustomer, err := ch.customerHandler.GetCustomer(customerName)
 
-		// GetCustomer returning nil error means existing customer was found
-		if err == nil {
+		// GetCustomer returning an error means no existing customer was found
+		if err != nil || (existing != nil && matchingCustomer.ID == existing.ID) {
 			return customerName, nil
 		}
 
@@ -329,8 +547,11 @@ func (ch *CommandHandler) getCustomerName() (string, error) {
 	}
 }
 
-func (ch *CommandHandler) getCustomerGridX() (int, error) {
+func (ch *CommandHandler) getCustomerGridX(existing *customerhandler.Customer) (int, error) {
 	prompt := "\nPlease provide customers grid X coordinate:"
+	if existing != nil {
+		prompt = "\nPlease provide customers grid X coordinate" + keepValueHint(existing.GridX) + ":"
+	}
 
 	for {
 		customerGridXString, err := ch.cliHandler.GetUserInput(prompt)
@@ -342,6 +563,10 @@ func (ch *CommandHandler) getCustomerGridX() (int, error) {
 			return -1, errKeywordEscape
 		}
 
+		if existing != nil && customerGridXString == "" {
+			return existing.GridX, nil
+		}
+
 		customerGridX, err := strconv.ParseInt(customerGridXString, 10, 0)
 		if err != nil {
 			prompt = "\nError parsing value, please provide a single numerical value:"
@@ -363,8 +588,11 @@ func (ch *CommandHandler) getCustomerGridX() (int, error) {
 	}
 }
 
-func (ch *CommandHandler) getCustomerGridY() (int, error) {
+func (ch *CommandHandler) getCustomerGridY(existing *customerhandler.Customer) (int, error) {
 	prompt := "\nPlease provide customers grid Y coordinate:"
+	if existing != nil {
+		prompt = "\nPlease provide customers grid Y coordinate" + keepValueHint(existing.GridY) + ":"
+	}
 
 	for {
 		customerGridYString, err := ch.cliHandler.GetUserInput(prompt)
@@ -376,6 +604,10 @@ func (ch *CommandHandler) getCustomerGridY() (int, error) {
 			return -1, errKeywordEscape
 		}
 
+		if existing != nil && customerGridYString == "" {
+			return existing.GridY, nil
+		}
+
 		customerGridY, err := strconv.ParseInt(customerGridYString, 10, 0)
 		if err != nil {
 			prompt = "\nError parsing value, please provide a single numerical value:"
@@ -397,127 +629,581 @@ func (ch *CommandHandler) getCustomerGridY() (int, error) {
 	}
 }
 
-func (ch *CommandHandler) getCustomerInputs() (customerhandler.Customer, error) {
-	customerName, err := ch.getCustomerName()
-	if err != nil {
-		return customerhandler.Customer{}, err
+// Prompt for an optional free text value. If editing an existing value,
+// a blank input keeps it and "-" clears it.
+func (ch *CommandHandler) getOptionalText(prompt string, existing *string) (string, error) {
+	if existing != nil && *existing != "" {
+		prompt += keepValueHint(*existing) + `, or "-" to clear`
 	}
 
-	customerGridX, err := ch.getCustomerGridX()
+	value, err := ch.cliHandler.GetUserInput("\n" + prompt + ":")
 	if err != nil {
-		return customerhandler.Customer{}, err
+		return "", wrapError(err)
 	}
 
-	customerGridY, err := ch.getCustomerGridY()
-	if err != nil {
-		return customerhandler.Customer{}, err
+	if ch.checkForKeywords(value) {
+		return "", errKeywordEscape
 	}
 
-	return customerhandler.Customer{
-		Name:  customerName,
-		GridX: customerGridX,
-		GridY: customerGridY,
-	}, nil
+	switch {
+	case value == "-":
+		return "", nil
+
+	case value == "" && existing != nil:
+		return *existing, nil
+
+	default:
+		return strings.TrimSpace(value), nil
+	}
 }
 
-func (ch *CommandHandler) handleManageCustomers() error {
-	ch.cliHandler.ClearTerminal()
+func (ch *CommandHandler) getCustomerAddress(existing *customerhandler.Customer) (customerhandler.Address, error) {
+	address := customerhandler.Address{}
+	if existing != nil {
+		address = existing.Address
+	}
 
-	selection, err := ch.cliHandler.GetUserInput(adminCustomerMenu)
-	if err != nil {
-		return wrapError(err)
+	fields := []struct {
+		prompt string
+		value  *string
+	}{
+		{"Please provide address line 1 (optional)", &address.Line1},
+		{"Please provide address line 2 (optional)", &address.Line2},
+		{"Please provide town or city (optional)", &address.Town},
+		{"Please provide county (optional)", &address.County},
+		{"Please provide postcode (optional)", &address.Postcode},
 	}
 
-	if ch.checkForKeywords(selection) {
-		return nil
+	for _, field := range fields {
+		var current *string
+		if existing != nil {
+			current = field.value
+		}
+
+		value, err := ch.getOptionalText(field.prompt, current)
+		if err != nil {
+			return customerhandler.Address{}, err
+		}
+
+		*field.value = value
 	}
 
-	switch selection {
-	case "1": // Add Customer
-		newCustomer, err := ch.getCustomerInputs()
+	return address, nil
+}
+
+func (ch *CommandHandler) getContactEmail() (string, error) {
+	prompt := "\nPlease provide contact email (optional):"
+
+	for {
+		email, err := ch.cliHandler.GetUserInput(prompt)
 		if err != nil {
-			return err
+			return "", wrapError(err)
 		}
 
-		err = ch.customerHandler.AddCustomer(newCustomer)
+		if ch.checkForKeywords(email) {
+			return "", errKeywordEscape
+		}
+
+		email = strings.TrimSpace(email)
+		if email == "" || customerhandler.IsValidEmail(email) {
+			return email, nil
+		}
+
+		prompt = "\nInvalid email address, please try again:"
+	}
+}
+
+// Prompt for any number of customer contacts. If editing an existing customer,
+// its contacts are kept unless the admin chooses to re-enter them.
+func (ch *CommandHandler) getCustomerContacts(existing *customerhandler.Customer) ([]customerhandler.Contact, error) {
+	if existing != nil && len(existing.Contacts) > 0 {
+		selection, err := ch.cliHandler.GetUserInput(fmt.Sprintf(
+			"\nCustomer has %d contact(s), re-enter contacts? (y/N):", len(existing.Contacts),
+		))
 		if err != nil {
-			return wrapError(err)
+			return nil, wrapError(err)
+		}
+
+		if ch.checkForKeywords(selection) {
+			return nil, errKeywordEscape
 		}
 
-		return nil
+		if !strings.EqualFold(selection, "y") {
+			return existing.Contacts, nil
+		}
+	}
+
+	contacts := []customerhandler.Contact{}
 
-	case "2": // Remove Customer
-		customer, err := ch.customerSelectMenu()
+	for {
+		name, err := ch.getOptionalText("Please provide contact name (leave blank to finish adding contacts)", nil)
 		if err != nil {
-			return err
+			return nil, err
+		}
+
+		if name == "" {
+			return contacts, nil
 		}
 
-		err = ch.customerHandler.RemoveCustomer(customer)
+		phone, err := ch.getOptionalText("Please provide contact phone number (optional)", nil)
 		if err != nil {
-			return wrapError(err)
+			return nil, err
 		}
 
-		return nil
+		email, err := ch.getContactEmail()
+		if err != nil {
+			return nil, err
+		}
 
-	default:
-		return nil
+		contacts = append(contacts, customerhandler.Contact{Name: name, Phone: phone, Email: email})
 	}
 }
 
-//nolint:cyclop // function is still readable
-func (ch *CommandHandler) handleManageUsers() error {
-	ch.cliHandler.ClearTerminal()
+// Prompt for the details of a customer. If editing an existing customer,
+// its current details are kept for any blank inputs and its ID is preserved.
+func (ch *CommandHandler) getCustomerInputs(existing *customerhandler.Customer) (customerhandler.Customer, error) {
+	customerName, err := ch.getCustomerName(existing)
+	if err != nil {
+		return customerhandler.Customer{}, err
+	}
 
-	selection, err := ch.cliHandler.GetUserInput(adminUserMenu)
+	customerGridX, err := ch.getCustomerGridX(existing)
 	if err != nil {
-		return wrapError(err)
+		return customerhandler.Customer{}, err
 	}
 
-	if ch.checkForKeywords(selection) {
-		return nil
+	customerGridY, err := ch.getCustomerGridY(existing)
+	if err != nil {
+		return customerhandler.Customer{}, err
+	}
+
+	customer := customerhandler.Customer{}
+	if existing != nil {
+		customer = *existing
+	}
+
+	customer.Name = customerName
+	customer.GridX = customerGridX
+	customer.GridY = customerGridY
+
+	return ch.getCustomerDetails(customer, existing != nil)
+}
+
+// Prompt for the optional details of a customer, keeping the current values for blank inputs when editing.
+func (ch *CommandHandler) getCustomerDetails(
+	customer customerhandler.Customer,
+	editing bool,
+) (customerhandler.Customer, error) {
+	var existing *customerhandler.Customer
+	if editing {
+		existing = &customer
 	}
 
-	switch selection {
-	case "1": // Remove User
-		user, err := ch.userSelectMenu()
+	textFields := []struct {
+		prompt string
+		value  *string
+	}{
+		{"Please provide account number (optional)", &customer.AccountNumber},
+		{"Please provide notes (optional)", &customer.Notes},
+		{"Please provide delivery instructions (optional)", &customer.DeliveryInstructions},
+	}
+
+	for _, field := range textFields {
+		var current *string
+		if editing {
+			current = field.value
+		}
+
+		value, err := ch.getOptionalText(field.prompt, current)
 		if err != nil {
-			return err
+			return customerhandler.Customer{}, err
 		}
 
-		if user.Username == ch.crmHandler.LoggedInUser.Username {
-			return wrapError(errNoSelfDelete)
+		*field.value = value
+	}
+
+	address, err := ch.getCustomerAddress(existing)
+	if err != nil {
+		return customerhandler.Customer{}, err
+	}
+
+	coordinates, err := ch.getCustomerCoordinates(existing, address.Postcode)
+	if err != nil {
+		return customerhandler.Customer{}, err
+	}
+
+	contacts, err := ch.getCustomerContacts(existing)
+	if err != nil {
+		return customerhandler.Customer{}, err
+	}
+
+	var currentTags *string
+	if editing {
+		joinedTags := strings.Join(customer.Tags, ", ")
+		currentTags = &joinedTags
+	}
+
+	tags, err := ch.getOptionalText("Please provide tags, separated by commas (optional)", currentTags)
+	if err != nil {
+		return customerhandler.Customer{}, err
+	}
+
+	region, err := ch.getCustomerRegion(existing)
+	if err != nil {
+		return customerhandler.Customer{}, err
+	}
+
+	customer.Address = address
+	customer.Coordinates = coordinates
+	customer.Contacts = contacts
+	customer.Tags = customerhandler.NormaliseTags(strings.Split(tags, ","))
+	customer.Region = region
+
+	return customer, nil
+}
+
+// Prompt for a customer's real-world coordinates. A blank input keeps the existing coordinates when editing,
+// otherwise looks the postcode up in the configured postcode file. Projected coordinates replace the grid location.
+func (ch *CommandHandler) getCustomerCoordinates(
+	existing *customerhandler.Customer,
+	postcode string,
+) (*geohandler.Coordinates, error) {
+	prompt := "\nPlease provide latitude, longitude (optional, leave blank to look up from postcode)"
+	if existing != nil {
+		prompt = "\nPlease provide latitude, longitude (optional, leave blank to keep none, or \"postcode\" to look them up)"
+		if existing.Coordinates != nil {
+			prompt = "\nPlease provide latitude, longitude" + keepValueHint(existing.Coordinates) +
+				", \"postcode\" to look them up, or \"-\" to clear"
 		}
+	}
 
-		err = ch.crmHandler.RemoveUser(user)
+	for {
+		value, err := ch.cliHandler.GetUserInput(prompt + ":")
 		if err != nil {
-			return wrapError(err)
+			return nil, wrapError(err)
 		}
 
-		return nil
+		if ch.checkForKeywords(value) {
+			return nil, errKeywordEscape
+		}
 
-	case "2": // Change User Type
-		user, err := ch.userSelectMenu()
-		if err != nil {
-			return err
+		var coordinates *geohandler.Coordinates
+
+		switch value = strings.TrimSpace(value); {
+		case value == "-":
+			return nil, nil //nolint:nilnil // no coordinates is a valid answer
+
+		// Blank keeps the existing value when editing, as for every other detail
+		case value == "" && existing != nil:
+			return existing.Coordinates, nil
+
+		case value == "":
+			coordinates = ch.customerHandler.LookupPostcode(postcode)
+
+		case strings.EqualFold(value, "postcode") && postcode == "":
+			ch.cliHandler.WriteOutput("No postcode was entered to look up, leave blank to skip")
+
+			continue
+
+		case strings.EqualFold(value, "postcode"):
+			coordinates = ch.customerHandler.LookupPostcode(postcode)
+			if coordinates == nil {
+				ch.cliHandler.WriteOutput(fmt.Sprintf("Postcode %q is not in the postcode file", postcode))
+
+				continue
+			}
+
+		default:
+			parsed, err := geohandler.ParseCoordinates(value)
+			if err != nil {
+				ch.cliHandler.WriteOutput(err.Error())
+
+				continue
+			}
+
+			coordinates = &parsed
 		}
 
-		if user.Username == ch.crmHandler.LoggedInUser.Username {
-			return wrapError(errNoSelfRoleEdit)
+		if coordinates != nil && ch.config.GridProjection().Enabled() {
+			gridX, gridY := ch.config.GridProjection().ToGrid(*coordinates)
+			ch.cliHandler.WriteOutput(fmt.Sprintf("Grid location set to %d, %d from coordinates %s", gridX, gridY, coordinates))
 		}
 
-		role, err := ch.roleSelectMenu()
-		if err != nil {
-			return err
+		return coordinates, nil
+	}
+}
+
+// Prompt for the region a customer is assigned to. A blank region means it is derived from the grid location.
+func (ch *CommandHandler) getCustomerRegion(existing *customerhandler.Customer) (string, error) {
+	regionNames := ch.customerHandler.RegionNames()
+	if len(regionNames) == 0 {
+		return "", nil
+	}
+
+	prompt := "\nSelect region:\n\n0 - Automatic (based on grid location)\n"
+	for i, name := range regionNames {
+		prompt += fmt.Sprintf("%d - %s\n", i+1, name)
+	}
+
+	if existing != nil {
+		current := existing.Region
+		if current == "" {
+			current = "Automatic"
 		}
 
-		err = ch.crmHandler.SetUserRole(user, role)
+		prompt += keepValueHint(current)
+	}
+
+	for {
+		selection, err := ch.cliHandler.GetUserInput(prompt)
 		if err != nil {
-			return wrapError(err)
+			return "", wrapError(err)
 		}
 
-		return nil
+		if ch.checkForKeywords(selection) {
+			return "", errKeywordEscape
+		}
 
-	default:
-		return nil
+		if selection == "" && existing != nil {
+			return existing.Region, nil
+		}
+
+		index, err := strconv.Atoi(selection)
+		if err == nil && index == 0 {
+			return "", nil
+		}
+
+		if err == nil && index >= 1 && index <= len(regionNames) {
+			return regionNames[index-1], nil
+		}
+
+		ch.cliHandler.WriteOutput(errInvalidSelection.Error())
+	}
+}
+
+func (ch *CommandHandler) customerMenu() []menuItem {
+	return []menuItem{
+		{
+			label:       "Add Customer",
+			permissions: []crmhandler.Permission{crmhandler.PermissionCustomersWrite},
+			action:      ch.handleAddCustomer,
+		},
+		{
+			label:       "Remove Customer",
+			permissions: []crmhandler.Permission{crmhandler.PermissionCustomersWrite},
+			action:      ch.handleRemoveCustomer,
+		},
+		{
+			label:       "Edit Customer",
+			permissions: []crmhandler.Permission{crmhandler.PermissionCustomersWrite},
+			action:      ch.handleEditCustomer,
+		},
+		{
+			label:       "Import Customers (CSV)",
+			permissions: []crmhandler.Permission{crmhandler.PermissionCustomersWrite},
+			action:      ch.handleImportCustomers,
+		},
+		{
+			label:       "Export Customers (CSV / JSON)",
+			permissions: []crmhandler.Permission{crmhandler.PermissionCustomersExport},
+			action:      ch.handleExportCustomers,
+		},
+		{
+			label:       "Customer Groups (Regions / Tags)",
+			permissions: []crmhandler.Permission{crmhandler.PermissionCustomersRead},
+			action:      ch.handleCustomerGroups,
+		},
+		{
+			label:       "Set Pricing Agreement",
+			permissions: []crmhandler.Permission{crmhandler.PermissionPricingWrite},
+			action:      ch.handleSetPricing,
+		},
+	}
+}
+
+func (ch *CommandHandler) handleAddCustomer() error {
+	newCustomer, err := ch.getCustomerInputs(nil)
+	if err != nil {
+		return err
+	}
+
+	err = ch.customerHandler.AddCustomer(newCustomer, ch.crmHandler.LoggedInUser().ID)
+	if err != nil {
+		return wrapError(err)
 	}
+
+	return nil
+}
+
+func (ch *CommandHandler) handleRemoveCustomer() error {
+	customer, err := ch.customerSelectMenu()
+	if err != nil {
+		return err
+	}
+
+	err = ch.customerHandler.RemoveCustomer(customer.ID, ch.crmHandler.LoggedInUser().ID)
+	if err != nil {
+		return wrapError(err)
+	}
+
+	return nil
+}
+
+func (ch *CommandHandler) handleEditCustomer() error {
+	customer, err := ch.customerSelectMenu()
+	if err != nil {
+		return err
+	}
+
+	updatedCustomer, err := ch.getCustomerInputs(&customer)
+	if err != nil {
+		return err
+	}
+
+	err = ch.customerHandler.UpdateCustomer(updatedCustomer, ch.crmHandler.LoggedInUser().ID)
+	if err != nil {
+		return wrapError(err)
+	}
+
+	return nil
+}
+
+func (ch *CommandHandler) userMenu() []menuItem {
+	items := []menuItem{
+		{
+			label:       ch.pendingApprovalsLabel(),
+			permissions: []crmhandler.Permission{crmhandler.PermissionUsersManage},
+			action:      ch.handlePendingApprovals,
+		},
+		{
+			label:       "Remove User",
+			permissions: []crmhandler.Permission{crmhandler.PermissionUsersManage},
+			action:      ch.handleRemoveUser,
+		},
+		{
+			label:       "Change User Role",
+			permissions: []crmhandler.Permission{crmhandler.PermissionUsersManage},
+			action:      ch.handleChangeUserRole,
+		},
+		{
+			label:       "Unlock User",
+			permissions: []crmhandler.Permission{crmhandler.PermissionUsersManage},
+			action:      ch.handleUnlockUser,
+		},
+		{
+			label:       "Reset Password",
+			permissions: []crmhandler.Permission{crmhandler.PermissionUsersManage},
+			action:      ch.handleResetPassword,
+		},
+		{
+			label:       "Reset Two-Factor Authentication",
+			permissions: []crmhandler.Permission{crmhandler.PermissionUsersManage},
+			action:      ch.handleResetTwoFactor,
+		},
+	}
+
+	// Invite codes are only needed when registration is invite-only
+	if ch.crmHandler.InviteOnly() {
+		items = append(items, menuItem{
+			label:       "Create Invite Code",
+			permissions: []crmhandler.Permission{crmhandler.PermissionUsersManage},
+			action:      ch.handleCreateInvite,
+		})
+	}
+
+	return items
+}
+
+func (ch *CommandHandler) handleChangePassword() error {
+	ch.cliHandler.ClearTerminal()
+	ch.cliHandler.WriteOutput("Change Password")
+
+	err := ch.crmHandler.ChangePassword()
+	if err != nil {
+		return wrapError(err)
+	}
+
+	ch.anyKeyToContinue()
+
+	return nil
+}
+
+// Reset the selected user's password to a temporary password, shown once so it can be passed on to the user.
+func (ch *CommandHandler) handleResetPassword() error {
+	user, err := ch.userSelectMenu()
+	if err != nil {
+		return err
+	}
+
+	if user.ID == ch.crmHandler.LoggedInUser().ID {
+		return wrapError(errNoSelfReset)
+	}
+
+	password, err := ch.crmHandler.ResetPassword(user.ID, ch.crmHandler.LoggedInUser().ID)
+	if err != nil {
+		return wrapError(err)
+	}
+
+	ch.cliHandler.WriteOutput(fmt.Sprintf(
+		"\nTemporary password for %s: %s\nIt will not be shown again, and must be changed at next login.",
+		user.Username, password,
+	))
+	ch.anyKeyToContinue()
+
+	return nil
+}
+
+func (ch *CommandHandler) handleUnlockUser() error {
+	user, err := ch.userSelectMenu()
+	if err != nil {
+		return err
+	}
+
+	err = ch.crmHandler.UnlockUser(user.ID, ch.crmHandler.LoggedInUser().ID)
+	if err != nil {
+		return wrapError(err)
+	}
+
+	return nil
+}
+
+func (ch *CommandHandler) handleRemoveUser() error {
+	user, err := ch.userSelectMenu()
+	if err != nil {
+		return err
+	}
+
+	if user.ID == ch.crmHandler.LoggedInUser().ID {
+		return wrapError(errNoSelfDelete)
+	}
+
+	err = ch.crmHandler.RemoveUser(user.ID, ch.crmHandler.LoggedInUser().ID)
+	if err != nil {
+		return wrapError(err)
+	}
+
+	return nil
+}
+
+func (ch *CommandHandler) handleChangeUserRole() error {
+	user, err := ch.userSelectMenu()
+	if err != nil {
+		return err
+	}
+
+	if user.ID == ch.crmHandler.LoggedInUser().ID {
+		return wrapError(errNoSelfRoleEdit)
+	}
+
+	role, err := ch.roleSelectMenu()
+	if err != nil {
+		return err
+	}
+
+	err = ch.crmHandler.SetUserRole(user.ID, role, ch.crmHandler.LoggedInUser().ID)
+	if err != nil {
+		return wrapError(err)
+	}
+
+	return nil
 }
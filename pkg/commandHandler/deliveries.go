@@ -0,0 +1,87 @@
+package commandhandler
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	customerhandler "work-mini-project/pkg/customerHandler"
+	deliveryhandler "work-mini-project/pkg/deliveryHandler"
+	transporthandler "work-mini-project/pkg/transportHandler"
+
+	"github.com/jedib0t/go-pretty/v6/table"
+)
+
+// Number of past deliveries listed on the customer detail screen.
+const deliveryHistoryLength = 10
+
+// Format a duration as hours, minutes and seconds. Hours are not wrapped at a day, so long trips read correctly.
+func formatDuration(duration time.Duration) string {
+	hours := int(duration.Hours())
+	minutes := int((duration % time.Hour) / time.Minute)
+	seconds := int((duration % time.Minute) / time.Second)
+
+	return fmt.Sprintf("%02d:%02d:%02d", hours, minutes, seconds)
+}
+
+// Book a quoted trip as a delivery to the customer.
+func (ch *CommandHandler) bookDelivery(customer customerhandler.Customer, trip *transporthandler.TripDetails) error {
+	delivery, err := ch.deliveryHandler.RecordDelivery(deliveryhandler.Delivery{
+		CustomerID: customer.ID,
+		Method:     trip.Method,
+		Distance:   trip.Distance,
+		Duration:   trip.Duration,
+		Cost:       trip.Cost,
+		ListCost:   trip.ListCost,
+		BookedBy:   ch.crmHandler.LoggedInUser().ID,
+	})
+	if err != nil {
+		return wrapError(err)
+	}
+
+	ch.cliHandler.WriteOutput(fmt.Sprintf("Booked delivery by %s for £%.2f", delivery.Method, delivery.Cost))
+	ch.anyKeyToContinue()
+
+	return nil
+}
+
+// Render a customer's delivery statistics and most recent deliveries.
+func renderDeliveryHistory(deliveries []deliveryhandler.Delivery) string {
+	if len(deliveries) == 0 {
+		return "No deliveries recorded"
+	}
+
+	summary := deliveryhandler.Summarise(deliveries)
+
+	methods := make([]string, 0, len(summary.MethodCounts))
+	for _, method := range transporthandler.Methods() {
+		if count := summary.MethodCounts[method]; count > 0 {
+			methods = append(methods, fmt.Sprintf("%s (%d)", method, count))
+		}
+	}
+
+	summaryTable := table.NewWriter()
+	summaryTable.SetTitle("Delivery Summary")
+	summaryTable.AppendRows([]table.Row{
+		{"Deliveries", summary.DeliveryCount},
+		{"Total Spend", fmt.Sprintf("£%.2f", summary.TotalSpend)},
+		{"Preferred Method", summary.PreferredMethod},
+		{"Methods Used", strings.Join(methods, ", ")},
+		{"Average Lead Time", formatDuration(summary.AverageLeadTime)},
+		{"Last Delivery", summary.LastDelivery.Format(time.DateTime)},
+	})
+
+	historyTable := table.NewWriter()
+	historyTable.SetTitle("Recent Deliveries")
+	historyTable.AppendHeader(table.Row{"Booked", "Transport Method", "Lead Time", "Cost"})
+
+	for _, delivery := range deliveries[:min(len(deliveries), deliveryHistoryLength)] {
+		historyTable.AppendRow(table.Row{
+			delivery.BookedAt.Format(time.DateTime),
+			delivery.Method,
+			formatDuration(delivery.Duration),
+			fmt.Sprintf("£%.2f", delivery.Cost),
+		})
+	}
+
+	return summaryTable.Render() + "\n\n" + historyTable.Render()
+}
@@ -0,0 +1,26 @@
+package commandhandler
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFormatDuration(t *testing.T) {
+	tests := []struct {
+		duration time.Duration
+		want     string
+	}{
+		{0, "00:00:00"},
+		{90 * time.Second, "00:01:30"},
+		{time.Hour + 2*time.Minute + 3*time.Second + 900*time.Millisecond, "01:02:03"}, // Part seconds are dropped
+		{23*time.Hour + 59*time.Minute + 59*time.Second, "23:59:59"},
+		{25*time.Hour + 30*time.Minute, "25:30:00"},
+		{100 * time.Hour, "100:00:00"},
+	}
+
+	for _, test := range tests {
+		if got := formatDuration(test.duration); got != test.want {
+			t.Errorf("formatDuration(%v) = %q, want %q", test.duration, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package commandhandler
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	crmhandler "work-mini-project/pkg/crmHandler"
+
+	"github.com/jedib0t/go-pretty/v6/table"
+)
+
+func (ch *CommandHandler) getFilePath(prompt string) (string, error) {
+	for {
+		filePath, err := ch.cliHandler.GetUserInput(prompt)
+		if err != nil {
+			return "", wrapError(err)
+		}
+
+		if ch.checkForKeywords(filePath) {
+			return "", errKeywordEscape
+		}
+
+		filePath = strings.TrimSpace(filePath)
+		if filePath != "" {
+			return filePath, nil
+		}
+
+		prompt = "\nFile path cannot be blank, please try again:"
+	}
+}
+
+// Import customers from a CSV file, showing a dry run report and asking for confirmation before adding them.
+func (ch *CommandHandler) handleImportCustomers() error {
+	filePath, err := ch.getFilePath(
+		"\nPlease provide the path of the CSV file to import (columns: " + customerCSVColumnsHelp + "):",
+	)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Open(filePath)
+	if err != nil {
+		return wrapError(err)
+	}
+	defer file.Close()
+
+	report, err := ch.customerHandler.ImportCSV(file, true, ch.crmHandler.LoggedInUser().ID)
+	if err != nil {
+		return wrapError(err)
+	}
+
+	if len(report.Errors) > 0 {
+		errorTable := table.NewWriter()
+		errorTable.AppendHeader(table.Row{"Row", "Error"})
+
+		for _, rowError := range report.Errors {
+			errorTable.AppendRow(table.Row{rowError.Row, rowError.Err})
+		}
+
+		ch.cliHandler.WriteOutput(fmt.Sprintf(
+			"\nImport cancelled, %d row(s) are invalid. No customers were added:\n", len(report.Errors),
+		))
+		ch.cliHandler.WriteOutput(errorTable.Render())
+		ch.anyKeyToContinue()
+
+		return nil
+	}
+
+	confirmation, err := ch.cliHandler.GetUserInput(fmt.Sprintf(
+		"\nAll rows are valid, import %d customer(s)? (y/N):", len(report.Customers),
+	))
+	if err != nil {
+		return wrapError(err)
+	}
+
+	if ch.checkForKeywords(confirmation) || !strings.EqualFold(confirmation, "y") {
+		return nil
+	}
+
+	// Rewind and import for real, revalidating in case the store changed while waiting for confirmation
+	_, err = file.Seek(0, 0)
+	if err != nil {
+		return wrapError(err)
+	}
+
+	report, err = ch.customerHandler.ImportCSV(file, false, ch.crmHandler.LoggedInUser().ID)
+	if err != nil {
+		return wrapError(err)
+	}
+
+	if !report.Committed {
+		return wrapError(errImportChanged)
+	}
+
+	ch.cliHandler.WriteOutput(fmt.Sprintf("\nSuccessfully imported %d customer(s)", len(report.Customers)))
+	ch.anyKeyToContinue()
+
+	return nil
+}
+
+func (ch *CommandHandler) handleExportCustomers() error {
+	filePath, err := ch.getFilePath("\nPlease provide the path of the file to export to (.csv or .json):")
+	if err != nil {
+		return err
+	}
+
+	exported, err := ch.customerHandler.ExportCustomers(filePath, ch.can(crmhandler.PermissionPricingView))
+	if err != nil {
+		return wrapError(err)
+	}
+
+	ch.cliHandler.WriteOutput(fmt.Sprintf("\nSuccessfully exported %d customer(s) to %s", exported, filePath))
+	ch.anyKeyToContinue()
+
+	return nil
+}
@@ -0,0 +1,49 @@
+package commandhandler
+
+import (
+	"fmt"
+	customerhandler "work-mini-project/pkg/customerHandler"
+	maphandler "work-mini-project/pkg/mapHandler"
+	transporthandler "work-mini-project/pkg/transportHandler"
+)
+
+// Show the grid map of the depot and customers, highlighting the customer and the route of the trip if one is given.
+func (ch *CommandHandler) showMap(customer customerhandler.Customer, trip *transporthandler.TripDetails) {
+	gridMap := maphandler.New(ch.config, ch.customerHandler.ActiveCustomers())
+
+	if trip != nil {
+		gridMap.AddRoute(customer, trip)
+	} else {
+		gridMap.Highlight(customer)
+	}
+
+	ch.cliHandler.WriteOutput(gridMap.RenderText(ch.cliHandler.TerminalWidth(), ch.config.Map.ASCII))
+}
+
+// Export a map of the routes of every quoted trip to the customer, colour-coded by transport method.
+func (ch *CommandHandler) exportQuoteMap(
+	customer customerhandler.Customer,
+	trips []*transporthandler.TripDetails,
+	filePath string,
+) {
+	if filePath == "" {
+		ch.cliHandler.WriteOutput(errInvalidSelection.Error())
+
+		return
+	}
+
+	gridMap := maphandler.New(ch.config, ch.customerHandler.ActiveCustomers())
+
+	for _, trip := range trips {
+		gridMap.AddRoute(customer, trip)
+	}
+
+	err := gridMap.Export(filePath)
+	if err != nil {
+		ch.cliHandler.WriteOutput(err.Error())
+
+		return
+	}
+
+	ch.cliHandler.WriteOutput(fmt.Sprintf("Exported map to %s", filePath))
+}
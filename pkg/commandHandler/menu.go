@@ -0,0 +1,104 @@
+package commandhandler
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	crmhandler "work-mini-project/pkg/crmHandler"
+)
+
+var errPermissionDenied = errors.New("error, you do not have permission to do that")
+
+// A menuItem is an action listed in a menu, shown only to users granted all of its permissions.
+// Items with a submenu are shown if any of the submenu's items are.
+type menuItem struct {
+	label       string
+	permissions []crmhandler.Permission
+	action      func() error
+	submenu     []menuItem
+	title       string // Shown above the submenu's items, instead of the default title
+}
+
+// Check whether the logged in user has been granted a permission.
+func (ch *CommandHandler) can(permission crmhandler.Permission) bool {
+	return ch.crmHandler.LoggedInUserHasPermission(permission)
+}
+
+// Central permission check for every menu action.
+func (ch *CommandHandler) authorise(permissions []crmhandler.Permission) error {
+	for _, permission := range permissions {
+		if !ch.can(permission) {
+			return errPermissionDenied
+		}
+	}
+
+	return nil
+}
+
+// Menu items the logged in user may use, in order.
+func (ch *CommandHandler) permittedItems(items []menuItem) []menuItem {
+	permitted := []menuItem{}
+
+	for _, item := range items {
+		if item.submenu != nil {
+			if len(ch.permittedItems(item.submenu)) > 0 {
+				permitted = append(permitted, item)
+			}
+
+			continue
+		}
+
+		if ch.authorise(item.permissions) == nil {
+			permitted = append(permitted, item)
+		}
+	}
+
+	return permitted
+}
+
+// Show a menu generated from the items the logged in user may use, and run the selected action.
+func (ch *CommandHandler) runMenu(title string, items []menuItem) error {
+	permitted := ch.permittedItems(items)
+
+	prompt := title + "\n"
+	for i, item := range permitted {
+		prompt += fmt.Sprintf("\n%d - %s", i+1, item.label)
+	}
+
+	selection, err := ch.cliHandler.GetUserInput(prompt)
+	if err != nil {
+		return wrapError(err)
+	}
+
+	if ch.checkForKeywords(selection) {
+		return nil
+	}
+
+	index, err := strconv.Atoi(selection)
+	if err != nil || index < 1 || index > len(permitted) {
+		ch.cliHandler.ClearTerminal()
+
+		return errUnrecognisedCommand(selection)
+	}
+
+	item := permitted[index-1]
+
+	if item.submenu != nil {
+		ch.cliHandler.ClearTerminal()
+
+		title := selectActionText
+		if item.title != "" {
+			title = item.title
+		}
+
+		return ch.runMenu(title, item.submenu)
+	}
+
+	// Every action passes through this check, whichever menu it is run from
+	err = ch.authorise(item.permissions)
+	if err != nil {
+		return err
+	}
+
+	return item.action()
+}
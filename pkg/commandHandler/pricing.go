@@ -0,0 +1,224 @@
+package commandhandler
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	customerhandler "work-mini-project/pkg/customerHandler"
+	transporthandler "work-mini-project/pkg/transportHandler"
+
+	"github.com/jedib0t/go-pretty/v6/table"
+)
+
+func renderPricingAgreement(pricing *customerhandler.PricingAgreement) string {
+	pricingTable := table.NewWriter()
+	pricingTable.SetTitle("Pricing Agreement")
+
+	pricingTable.AppendRows([]table.Row{
+		{"Discount", fmt.Sprintf("%.2f%%", pricing.DiscountPercent)},
+		{"Surcharge", fmt.Sprintf("£%.2f", pricing.Surcharge)},
+		{"Minimum Charge", fmt.Sprintf("£%.2f", pricing.MinimumCharge)},
+	})
+
+	for _, method := range transporthandler.Methods() {
+		pricingTable.AppendRow(table.Row{method + " Rate", describeRateCard(pricing, method)})
+	}
+
+	return pricingTable.Render()
+}
+
+func describeRateCard(pricing *customerhandler.PricingAgreement, method string) string {
+	rateCard, ok := pricing.RateCards[method]
+	if !ok {
+		return "List price"
+	}
+
+	return fmt.Sprintf("£%.2f + £%.2f per unit distance", rateCard.BaseCharge, rateCard.PerDistanceUnit)
+}
+
+// Prompt for a non-negative amount, no greater than maximum if it is above 0.
+// A blank input keeps the current value.
+func (ch *CommandHandler) getAmount(prompt string, current float64, maximum float64) (float64, error) {
+	prompt = fmt.Sprintf("\n%s%s:", prompt, keepValueHint(strconv.FormatFloat(current, 'f', -1, 64)))
+
+	for {
+		input, err := ch.cliHandler.GetUserInput(prompt)
+		if err != nil {
+			return 0, wrapError(err)
+		}
+
+		if ch.checkForKeywords(input) {
+			return 0, errKeywordEscape
+		}
+
+		input = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(input), "£"))
+		if input == "" {
+			return current, nil
+		}
+
+		amount, err := strconv.ParseFloat(input, 64)
+		if err != nil || amount < 0 || (maximum > 0 && amount > maximum) {
+			prompt = "\nInvalid value, please provide a positive number"
+			if maximum > 0 {
+				prompt += fmt.Sprintf(" no greater than %g", maximum)
+			}
+
+			prompt += ":"
+
+			continue
+		}
+
+		return amount, nil
+	}
+}
+
+// Prompt for whether a transport method uses a rate card or the list price, and the rate card values if so.
+// Returns nil if the method uses the list price.
+//
+//nolint:nilnil // nil rate card means the list price is used
+func (ch *CommandHandler) getRateCard(
+	pricing *customerhandler.PricingAgreement,
+	method string,
+) (*customerhandler.RateCard, error) {
+	current, hasRateCard := pricing.RateCards[method]
+
+	prompt := fmt.Sprintf(`
+%s rate (currently %s):
+
+1 - Keep current rate
+2 - Use list price
+3 - Set rate card`, method, describeRateCard(pricing, method))
+
+	for {
+		selection, err := ch.cliHandler.GetUserInput(prompt)
+		if err != nil {
+			return nil, wrapError(err)
+		}
+
+		if ch.checkForKeywords(selection) {
+			return nil, errKeywordEscape
+		}
+
+		switch selection {
+		case "1", "":
+			if !hasRateCard {
+				return nil, nil
+			}
+
+			return &current, nil
+
+		case "2":
+			return nil, nil
+
+		case "3":
+			baseCharge, err := ch.getAmount("Please provide the base charge", current.BaseCharge, 0)
+			if err != nil {
+				return nil, err
+			}
+
+			perDistanceUnit, err := ch.getAmount(
+				"Please provide the charge per unit of distance", current.PerDistanceUnit, 0,
+			)
+			if err != nil {
+				return nil, err
+			}
+
+			return &customerhandler.RateCard{BaseCharge: baseCharge, PerDistanceUnit: perDistanceUnit}, nil
+
+		default:
+			ch.cliHandler.WriteOutput(errInvalidSelection.Error())
+		}
+	}
+}
+
+func (ch *CommandHandler) getPricingAgreement(
+	existing *customerhandler.PricingAgreement,
+) (*customerhandler.PricingAgreement, error) {
+	current := customerhandler.PricingAgreement{}
+	if existing != nil {
+		current = *existing
+	}
+
+	discountPercent, err := ch.getAmount(
+		"Please provide the discount percentage", current.DiscountPercent, customerhandler.MaxDiscountPercent,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	surcharge, err := ch.getAmount("Please provide the fixed surcharge per trip", current.Surcharge, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	minimumCharge, err := ch.getAmount("Please provide the minimum charge per trip", current.MinimumCharge, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	pricing := &customerhandler.PricingAgreement{
+		DiscountPercent: discountPercent,
+		Surcharge:       surcharge,
+		MinimumCharge:   minimumCharge,
+		RateCards:       map[string]customerhandler.RateCard{},
+	}
+
+	for _, method := range transporthandler.Methods() {
+		rateCard, err := ch.getRateCard(&current, method)
+		if err != nil {
+			return nil, err
+		}
+
+		if rateCard != nil {
+			pricing.RateCards[method] = *rateCard
+		}
+	}
+
+	return pricing, nil
+}
+
+func (ch *CommandHandler) handleSetPricing() error {
+	customer, err := ch.customerSelectMenu()
+	if err != nil {
+		return err
+	}
+
+	ch.cliHandler.ClearTerminal()
+	ch.cliHandler.WriteOutput(customer.Name)
+
+	if customer.Pricing != nil {
+		ch.cliHandler.WriteOutput(renderPricingAgreement(customer.Pricing))
+	}
+
+	selection, err := ch.cliHandler.GetUserInput(pricingAgreementMenu)
+	if err != nil {
+		return wrapError(err)
+	}
+
+	if ch.checkForKeywords(selection) {
+		return nil
+	}
+
+	var pricing *customerhandler.PricingAgreement
+
+	switch selection {
+	case "1": // Set Pricing Agreement
+		pricing, err = ch.getPricingAgreement(customer.Pricing)
+		if err != nil {
+			return err
+		}
+
+	case "2": // Remove Pricing Agreement
+		pricing = nil
+
+	default:
+		return nil
+	}
+
+	err = ch.customerHandler.SetPricing(customer.ID, pricing, ch.crmHandler.LoggedInUser().ID)
+	if err != nil {
+		return wrapError(err)
+	}
+
+	return nil
+}
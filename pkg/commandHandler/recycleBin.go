@@ -0,0 +1,184 @@
+package commandhandler
+
+import (
+	"errors"
+	"fmt"
+	"time"
+	crmhandler "work-mini-project/pkg/crmHandler"
+	customerhandler "work-mini-project/pkg/customerHandler"
+)
+
+var errRecycleBinEmpty = errors.New("error, there is nothing in the recycle bin")
+
+// Describe when and by whom a record was deleted, and when it can be purged.
+func (ch *CommandHandler) deletionLabel(deletedAt time.Time, deletedBy string, purgeableAt time.Time) string {
+	actor := "unknown user"
+	if user, err := ch.crmHandler.GetUserByID(deletedBy); err == nil {
+		actor = user.Username
+	}
+
+	purgeable := "purgeable now"
+	if time.Now().Before(purgeableAt) {
+		purgeable = "purgeable from " + purgeableAt.Format(time.DateOnly)
+	}
+
+	return fmt.Sprintf("deleted %s by %s, %s", deletedAt.Format(time.DateTime), actor, purgeable)
+}
+
+func (ch *CommandHandler) deletedCustomerSelectMenu() (customerhandler.Customer, error) {
+	err := ch.customerHandler.Reload()
+	if err != nil {
+		return customerhandler.Customer{}, wrapError(err)
+	}
+
+	deletedCustomers := ch.customerHandler.DeletedCustomers()
+	if len(deletedCustomers) == 0 {
+		return customerhandler.Customer{}, errRecycleBinEmpty
+	}
+
+	menu := newSelectMenu[customerhandler.Customer]("Select Deleted Customer:", nil)
+
+	for _, customer := range deletedCustomers {
+		label := fmt.Sprintf("%s (%s)", customer.Name, ch.deletionLabel(
+			*customer.DeletedAt, customer.DeletedBy, ch.customerHandler.PurgeableAt(customer),
+		))
+
+		menu.addOption(customer.Name, label, customer)
+	}
+
+	return selectFromMenu(ch, menu)
+}
+
+func (ch *CommandHandler) deletedUserSelectMenu() (crmhandler.User, error) {
+	err := ch.crmHandler.Reload()
+	if err != nil {
+		return crmhandler.User{}, wrapError(err)
+	}
+
+	deletedUsers := ch.crmHandler.DeletedUsers()
+	if len(deletedUsers) == 0 {
+		return crmhandler.User{}, errRecycleBinEmpty
+	}
+
+	menu := newSelectMenu[crmhandler.User]("Select Deleted User:", nil)
+
+	for _, user := range deletedUsers {
+		label := fmt.Sprintf("%s (%s)", user.Username, ch.deletionLabel(
+			*user.DeletedAt, user.DeletedBy, ch.crmHandler.PurgeableAt(user),
+		))
+
+		menu.addOption(user.Username, label, user)
+	}
+
+	return selectFromMenu(ch, menu)
+}
+
+func (ch *CommandHandler) recycleBinMenu() []menuItem {
+	return []menuItem{
+		{
+			label:       "Restore Customer",
+			permissions: []crmhandler.Permission{crmhandler.PermissionCustomersWrite},
+			action:      ch.handleRestoreCustomer,
+		},
+		{
+			label:       "Restore User",
+			permissions: []crmhandler.Permission{crmhandler.PermissionUsersManage},
+			action:      ch.handleRestoreUser,
+		},
+		{
+			label: "Purge Customer",
+			permissions: []crmhandler.Permission{
+				crmhandler.PermissionCustomersWrite, crmhandler.PermissionRecycleBinPurge,
+			},
+			action: ch.handlePurgeCustomer,
+		},
+		{
+			label:       "Purge User",
+			permissions: []crmhandler.Permission{crmhandler.PermissionUsersManage, crmhandler.PermissionRecycleBinPurge},
+			action:      ch.handlePurgeUser,
+		},
+		{
+			label: "Purge All Expired",
+			permissions: []crmhandler.Permission{
+				crmhandler.PermissionCustomersWrite, crmhandler.PermissionUsersManage, crmhandler.PermissionRecycleBinPurge,
+			},
+			action: ch.handlePurgeExpired,
+		},
+	}
+}
+
+func (ch *CommandHandler) handleRestoreCustomer() error {
+	customer, err := ch.deletedCustomerSelectMenu()
+	if err != nil {
+		return err
+	}
+
+	err = ch.customerHandler.RestoreCustomer(customer.ID, ch.crmHandler.LoggedInUser().ID)
+	if err != nil {
+		return wrapError(err)
+	}
+
+	return nil
+}
+
+func (ch *CommandHandler) handleRestoreUser() error {
+	user, err := ch.deletedUserSelectMenu()
+	if err != nil {
+		return err
+	}
+
+	err = ch.crmHandler.RestoreUser(user.ID, ch.crmHandler.LoggedInUser().ID)
+	if err != nil {
+		return wrapError(err)
+	}
+
+	return nil
+}
+
+func (ch *CommandHandler) handlePurgeCustomer() error {
+	customer, err := ch.deletedCustomerSelectMenu()
+	if err != nil {
+		return err
+	}
+
+	err = ch.customerHandler.PurgeCustomer(customer.ID, ch.crmHandler.LoggedInUser().ID)
+	if err != nil {
+		return wrapError(err)
+	}
+
+	return nil
+}
+
+func (ch *CommandHandler) handlePurgeUser() error {
+	user, err := ch.deletedUserSelectMenu()
+	if err != nil {
+		return err
+	}
+
+	err = ch.crmHandler.PurgeUser(user.ID, ch.crmHandler.LoggedInUser().ID)
+	if err != nil {
+		return wrapError(err)
+	}
+
+	return nil
+}
+
+func (ch *CommandHandler) handlePurgeExpired() error {
+	purgedCustomers, err := ch.customerHandler.PurgeExpiredCustomers(ch.crmHandler.LoggedInUser().ID)
+	if err != nil {
+		return wrapError(err)
+	}
+
+	purgedUsers, err := ch.crmHandler.PurgeExpiredUsers(ch.crmHandler.LoggedInUser().ID)
+	if err != nil {
+		return wrapError(err)
+	}
+
+	ch.cliHandler.WriteOutput(fmt.Sprintf(
+		"\nPermanently removed %d customer(s) and %d user(s), deleted more than %d day(s) ago",
+		purgedCustomers, purgedUsers, ch.config.RecycleBin.RetentionDays,
+	))
+	ch.anyKeyToContinue()
+
+	return nil
+}
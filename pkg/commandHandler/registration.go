@@ -0,0 +1,96 @@
+package commandhandler
+
+import (
+	"errors"
+	"fmt"
+	"time"
+	crmhandler "work-mini-project/pkg/crmHandler"
+)
+
+var errNoPendingUsers = errors.New("error, there are no registrations awaiting approval")
+
+// Label the Pending Approvals menu item with the number of registrations waiting, including any made since the
+// users were last read. If they can't be re-read the count is left out, and the error shown when the item is used.
+func (ch *CommandHandler) pendingApprovalsLabel() string {
+	err := ch.crmHandler.Reload()
+	if err != nil {
+		return "Pending Approvals"
+	}
+
+	return fmt.Sprintf("Pending Approvals (%d)", len(ch.crmHandler.PendingUsers()))
+}
+
+func (ch *CommandHandler) pendingUserSelectMenu() (crmhandler.User, error) {
+	err := ch.crmHandler.Reload()
+	if err != nil {
+		return crmhandler.User{}, wrapError(err)
+	}
+
+	pendingUsers := ch.crmHandler.PendingUsers()
+	if len(pendingUsers) == 0 {
+		return crmhandler.User{}, errNoPendingUsers
+	}
+
+	menu := newSelectMenu[crmhandler.User]("Select User Awaiting Approval:", nil)
+
+	for _, user := range pendingUsers {
+		menu.addOption(user.Username, user.Username, user)
+	}
+
+	return selectFromMenu(ch, menu)
+}
+
+// Approve or reject the selected pending registration.
+func (ch *CommandHandler) handlePendingApprovals() error {
+	user, err := ch.pendingUserSelectMenu()
+	if err != nil {
+		return err
+	}
+
+	return ch.runMenu(fmt.Sprintf("Registration of %s:\n%s", user.Username, selectActionText), []menuItem{
+		{
+			label:       "Approve",
+			permissions: []crmhandler.Permission{crmhandler.PermissionUsersManage},
+			action:      func() error { return ch.handleApproveUser(user) },
+		},
+		{
+			label:       "Reject",
+			permissions: []crmhandler.Permission{crmhandler.PermissionUsersManage},
+			action:      func() error { return ch.handleRejectUser(user) },
+		},
+	})
+}
+
+func (ch *CommandHandler) handleApproveUser(user crmhandler.User) error {
+	err := ch.crmHandler.ApproveUser(user.ID, ch.crmHandler.LoggedInUser().ID)
+	if err != nil {
+		return wrapError(err)
+	}
+
+	return nil
+}
+
+func (ch *CommandHandler) handleRejectUser(user crmhandler.User) error {
+	err := ch.crmHandler.RejectUser(user.ID, ch.crmHandler.LoggedInUser().ID)
+	if err != nil {
+		return wrapError(err)
+	}
+
+	return nil
+}
+
+// Create an invite code, shown once so it can be passed on to the person being invited.
+func (ch *CommandHandler) handleCreateInvite() error {
+	code, expiresAt, err := ch.crmHandler.CreateInvite(ch.crmHandler.LoggedInUser().ID)
+	if err != nil {
+		return wrapError(err)
+	}
+
+	ch.cliHandler.WriteOutput(fmt.Sprintf(
+		"\nInvite code: %s\nIt can be used to register one account until %s, and will not be shown again.",
+		code, expiresAt.Format(time.DateTime),
+	))
+	ch.anyKeyToContinue()
+
+	return nil
+}
@@ -0,0 +1,260 @@
+package commandhandler
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Number of options listed on each page of a select menu.
+const selectMenuPageSize = 10
+
+const selectMenuHelp = `Enter a number to select, "next" / "prev" to change page, "/<text>" to search by name,
+%s"clear" to remove search and filters, or "cancel" to go back`
+
+type selectOption[T any] struct {
+	name  string // Text matched by searches
+	label string
+	value T
+}
+
+// A selectFilter reports whether a value matches the argument given to the filter, e.g. "town:Leeds".
+type selectFilter[T any] func(value T, argument string) bool
+
+// A selectMenu lists options a page at a time, allowing them to be searched and filtered before one is selected.
+type selectMenu[T any] struct {
+	title   string
+	options []selectOption[T]
+	filters map[string]selectFilter[T]
+
+	search        string
+	activeFilters map[string]string
+	page          int
+}
+
+func newSelectMenu[T any](title string, filters map[string]selectFilter[T]) *selectMenu[T] {
+	return &selectMenu[T]{
+		title:         title,
+		options:       []selectOption[T]{},
+		filters:       filters,
+		activeFilters: map[string]string{},
+	}
+}
+
+func (menu *selectMenu[T]) addOption(name string, label string, value T) {
+	menu.options = append(menu.options, selectOption[T]{name: name, label: label, value: value})
+}
+
+// Options matching the active filters, ordered by how well they match the search if there is one.
+func (menu *selectMenu[T]) visibleOptions() []selectOption[T] {
+	type scoredOption struct {
+		option selectOption[T]
+		score  int
+	}
+
+	matches := []scoredOption{}
+
+	for _, option := range menu.options {
+		if !menu.matchesFilters(option.value) {
+			continue
+		}
+
+		score := 0
+
+		if menu.search != "" {
+			var ok bool
+
+			score, ok = searchScore(option.name, menu.search)
+			if !ok {
+				continue
+			}
+		}
+
+		matches = append(matches, scoredOption{option: option, score: score})
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].score < matches[j].score
+	})
+
+	visible := make([]selectOption[T], len(matches))
+	for i, match := range matches {
+		visible[i] = match.option
+	}
+
+	return visible
+}
+
+func (menu *selectMenu[T]) matchesFilters(value T) bool {
+	for name, argument := range menu.activeFilters {
+		if !menu.filters[name](value, argument) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Score how well a label matches a search, lower is better.
+// Substring matches rank ahead of fuzzy matches, where the search characters appear in order but not together.
+func searchScore(name string, search string) (int, bool) {
+	name = strings.ToLower(name)
+	search = strings.ToLower(search)
+
+	if index := strings.Index(name, search); index != -1 {
+		return index, true
+	}
+
+	// Fuzzy match, penalising gaps between matched characters
+	score := len(name)
+	nameRunes := []rune(name)
+	position := 0
+
+	for _, searchRune := range search {
+		gap := slices.Index(nameRunes[position:], searchRune)
+		if gap == -1 {
+			return 0, false
+		}
+
+		score += gap
+		position += gap + 1
+	}
+
+	return score, true
+}
+
+func (menu *selectMenu[T]) render(options []selectOption[T], message string) string {
+	pageCount := max(1, (len(options)+selectMenuPageSize-1)/selectMenuPageSize)
+	menu.page = min(max(menu.page, 0), pageCount-1)
+
+	output := menu.title + "\n"
+
+	if menu.search != "" {
+		output += fmt.Sprintf("Search: %q\n", menu.search)
+	}
+
+	filterNames := make([]string, 0, len(menu.activeFilters))
+	for name := range menu.activeFilters {
+		filterNames = append(filterNames, name)
+	}
+
+	slices.Sort(filterNames)
+
+	for _, name := range filterNames {
+		output += fmt.Sprintf("Filter: %s = %q\n", name, menu.activeFilters[name])
+	}
+
+	output += "\n"
+
+	if len(options) == 0 {
+		output += "No matches found\n"
+	}
+
+	start := menu.page * selectMenuPageSize
+	end := min(start+selectMenuPageSize, len(options))
+
+	for i := start; i < end; i++ {
+		output += fmt.Sprintf("%d - %s\n", i+1, options[i].label)
+	}
+
+	if len(options) > selectMenuPageSize {
+		output += fmt.Sprintf("\nShowing %d-%d of %d (page %d of %d)\n", start+1, end, len(options), menu.page+1, pageCount)
+	}
+
+	filterHelp := ""
+
+	if len(menu.filters) > 0 {
+		names := make([]string, 0, len(menu.filters))
+		for name := range menu.filters {
+			names = append(names, name+":<value>")
+		}
+
+		slices.Sort(names)
+
+		filterHelp = fmt.Sprintf("%s to filter, ", strings.Join(names, " / "))
+	}
+
+	output += "\n" + fmt.Sprintf(selectMenuHelp, filterHelp)
+
+	if message != "" {
+		output += "\n\n" + message
+	}
+
+	return output
+}
+
+// Apply a menu command to the menu state, returning false if the input isn't a menu command.
+func (menu *selectMenu[T]) handleCommand(input string) bool {
+	switch command := strings.ToLower(input); {
+	case command == "next" || command == "n":
+		menu.page++
+
+	case command == "prev" || command == "previous" || command == "p":
+		menu.page--
+
+	case command == "clear":
+		menu.search = ""
+		menu.activeFilters = map[string]string{}
+		menu.page = 0
+
+	case strings.HasPrefix(input, "/"):
+		menu.search = strings.TrimSpace(strings.TrimPrefix(input, "/"))
+		menu.page = 0
+
+	default:
+		name, argument, found := strings.Cut(input, ":")
+		name = strings.ToLower(strings.TrimSpace(name))
+
+		if _, ok := menu.filters[name]; !found || !ok {
+			return false
+		}
+
+		argument = strings.TrimSpace(argument)
+		if argument == "" {
+			delete(menu.activeFilters, name)
+		} else {
+			menu.activeFilters[name] = argument
+		}
+
+		menu.page = 0
+	}
+
+	return true
+}
+
+// Show a select menu until an option is selected, or a global keyword is entered.
+func selectFromMenu[T any](ch *CommandHandler, menu *selectMenu[T]) (T, error) {
+	var empty T
+
+	message := ""
+
+	for {
+		options := menu.visibleOptions()
+
+		selection, err := ch.cliHandler.GetUserInput(menu.render(options, message))
+		if err != nil {
+			return empty, wrapError(err)
+		}
+
+		if ch.checkForKeywords(selection) {
+			return empty, errKeywordEscape
+		}
+
+		message = ""
+
+		if menu.handleCommand(selection) {
+			continue
+		}
+
+		index, err := strconv.Atoi(strings.TrimSpace(selection))
+		if err != nil || index < 1 || index > len(options) {
+			message = errInvalidSelection.Error()
+
+			continue
+		}
+
+		return options[index-1].value, nil
+	}
+}
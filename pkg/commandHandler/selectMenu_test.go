@@ -0,0 +1,153 @@
+package commandhandler
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestSearchScore(t *testing.T) {
+	tests := []struct {
+		name      string
+		search    string
+		wantScore int
+		wantMatch bool
+	}{
+		{"Acme Logistics", "acme", 0, true},
+		{"Acme Logistics", "LOG", 5, true},
+		{"Acme Logistics", "als", 14 + 4 + 3, true}, // Fuzzy, scored by length plus the gaps between characters
+		{"Acme Logistics", "xyz", 0, false},
+		{"Acme Logistics", "sa", 0, false}, // Characters out of order
+	}
+
+	for _, test := range tests {
+		score, match := searchScore(test.name, test.search)
+		if score != test.wantScore || match != test.wantMatch {
+			t.Errorf("searchScore(%q, %q) = %d, %t, want %d, %t",
+				test.name, test.search, score, match, test.wantScore, test.wantMatch)
+		}
+	}
+}
+
+type testCustomer struct {
+	name string
+	town string
+}
+
+func newTestSelectMenu(customers ...testCustomer) *selectMenu[testCustomer] {
+	menu := newSelectMenu("Select Customer:", map[string]selectFilter[testCustomer]{
+		"town": func(customer testCustomer, town string) bool {
+			return strings.EqualFold(customer.town, town)
+		},
+	})
+
+	for _, customer := range customers {
+		menu.addOption(customer.name, customer.name, customer)
+	}
+
+	return menu
+}
+
+func optionNames(options []selectOption[testCustomer]) []string {
+	names := []string{}
+	for _, option := range options {
+		names = append(names, option.name)
+	}
+
+	return names
+}
+
+func TestSelectMenuSearchAndFilters(t *testing.T) {
+	customers := []testCustomer{
+		{"Northern Foods", "Leeds"},
+		{"Food Direct", "York"},
+		{"Leeds Fabrics", "Leeds"},
+		{"Fresh Orchards Direct", "Leeds"},
+	}
+
+	all := []string{"Northern Foods", "Food Direct", "Leeds Fabrics", "Fresh Orchards Direct"}
+
+	tests := []struct {
+		name     string
+		commands []string
+		want     []string
+	}{
+		{"no search or filter", nil, all},
+		{"substring matches ordered by position", []string{"/food"}, []string{"Food Direct", "Northern Foods"}},
+		{
+			"substring matches ahead of fuzzy matches",
+			[]string{"/or"},
+			[]string{"Northern Foods", "Fresh Orchards Direct", "Food Direct"},
+		},
+		{"filter", []string{"town:leeds"}, []string{"Northern Foods", "Leeds Fabrics", "Fresh Orchards Direct"}},
+		{"filter and search", []string{"town:Leeds", "/direct"}, []string{"Fresh Orchards Direct"}},
+		{"blank filter value removes the filter", []string{"town:York", "town:"}, all},
+		{"clear", []string{"town:York", "/food", "clear"}, all},
+		{"no matches", []string{"/zzz"}, []string{}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			menu := newTestSelectMenu(customers...)
+
+			for _, command := range test.commands {
+				if !menu.handleCommand(command) {
+					t.Fatalf("handleCommand(%q) = false, want true", command)
+				}
+			}
+
+			if got := optionNames(menu.visibleOptions()); !slices.Equal(got, test.want) {
+				t.Errorf("visibleOptions() = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestSelectMenuIgnoresOtherInput(t *testing.T) {
+	menu := newTestSelectMenu(testCustomer{"Food Direct", "York"})
+
+	for _, input := range []string{"1", "colour:red", "town", "cancel"} {
+		if menu.handleCommand(input) {
+			t.Errorf("handleCommand(%q) = true, want false", input)
+		}
+	}
+}
+
+func TestSelectMenuPaging(t *testing.T) {
+	customers := []testCustomer{}
+	for i := range 25 {
+		customers = append(customers, testCustomer{name: fmt.Sprintf("Customer %02d", i+1)})
+	}
+
+	tests := []struct {
+		commands []string
+		wantPage int
+	}{
+		{nil, 0},
+		{[]string{"next"}, 1},
+		{[]string{"n", "n"}, 2},
+		{[]string{"next", "next", "next", "next"}, 2}, // Clamped to the last page
+		{[]string{"prev"}, 0},                         // Clamped to the first page
+		{[]string{"next", "next", "/customer 1"}, 0},  // Searching returns to the first page
+	}
+
+	for _, test := range tests {
+		menu := newTestSelectMenu(customers...)
+
+		for _, command := range test.commands {
+			menu.handleCommand(command)
+		}
+
+		output := menu.render(menu.visibleOptions(), "")
+
+		if menu.page != test.wantPage {
+			t.Errorf("after %v, page = %d, want %d", test.commands, menu.page, test.wantPage)
+		}
+
+		first := fmt.Sprintf("%d - ", test.wantPage*selectMenuPageSize+1)
+		if !strings.Contains(output, first) {
+			t.Errorf("after %v, render() doesn't list option %s", test.commands, first)
+		}
+	}
+}
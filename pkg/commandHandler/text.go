@@ -24,24 +24,29 @@ Select a command by entering it's numerical value after the input prompt and pre
 	> 1 (enter)
 `
 
-const postLoginText = `Please select a function:
+// Titles of menus generated from the logged in user's permissions.
+const (
+	selectFunctionText = "Please select a function:"
+	selectActionText   = "\nSelect Action:"
+)
 
-1 - Calculate Journey
-`
-
-const adminPostLoginText = `2 - Manage Customers
-3 - Manage users`
-
-const adminCustomerMenu = `
+const pricingAgreementMenu = `
 Select Action:
 
-1 - Add Customer
-2 - Remove Customer
+1 - Set Pricing Agreement
+2 - Remove Pricing Agreement
 `
 
-const adminUserMenu = `
-Select Action:
+const customerCSVColumnsHelp = `name, gridX, gridY and optionally accountNumber, addressLine1, addressLine2, town, county,
+postcode, contacts ("name|phone|email" separated by ";"), notes, deliveryInstructions, tags (separated by ";"), region,
+latitude and longitude (which replace gridX and gridY when a projection is configured)`
 
-1 - Remove User
-2 - Change User Type
-`
+const bookDeliveryText = "Enter a transport method number to book a delivery.\n"
+
+const quoteActionsText = `Enter "map" to show the grid map, "map <number>" to show a transport method's route,
+"export <file path>" to save every route to a .svg or .png map, or leave blank to continue`
+
+const auditFilterText = `
+Enter filters separated by spaces to narrow the log, leave blank to show everything, "verify" to check the log
+hasn't been altered, or cancel to go back:
+user:<username>  action:<action, e.g. customer or user.role>  from:<YYYY-MM-DD>  to:<YYYY-MM-DD>`
@@ -0,0 +1,79 @@
+package commandhandler
+
+import "fmt"
+
+// Enrol the logged in user in two-factor authentication, or manage it if they already are.
+func (ch *CommandHandler) handleTwoFactor() error {
+	user, err := ch.crmHandler.GetUserByID(ch.crmHandler.LoggedInUser().ID)
+	if err != nil {
+		return wrapError(err)
+	}
+
+	ch.cliHandler.ClearTerminal()
+	ch.cliHandler.WriteOutput("Two-Factor Authentication")
+
+	if !user.HasTwoFactor() {
+		err = ch.crmHandler.EnrolTwoFactor(user.ID)
+		if err != nil {
+			return wrapError(err)
+		}
+
+		ch.anyKeyToContinue()
+
+		return nil
+	}
+
+	items := []menuItem{{label: "Regenerate Recovery Codes", action: ch.handleRegenerateRecoveryCodes}}
+
+	// Users whose role requires two-factor authentication can't turn it off
+	if !ch.crmHandler.RequiresTwoFactor(user) {
+		items = append(items, menuItem{label: "Disable Two-Factor Authentication", action: ch.handleDisableTwoFactor})
+	}
+
+	return ch.runMenu(
+		fmt.Sprintf("Two-factor authentication is enabled, with %d recovery code(s) remaining.\n%s",
+			len(user.RecoveryCodes), selectActionText),
+		items,
+	)
+}
+
+func (ch *CommandHandler) handleRegenerateRecoveryCodes() error {
+	err := ch.crmHandler.RegenerateRecoveryCodes()
+	if err != nil {
+		return wrapError(err)
+	}
+
+	ch.anyKeyToContinue()
+
+	return nil
+}
+
+func (ch *CommandHandler) handleDisableTwoFactor() error {
+	err := ch.crmHandler.DisableTwoFactor()
+	if err != nil {
+		return wrapError(err)
+	}
+
+	ch.anyKeyToContinue()
+
+	return nil
+}
+
+// Remove the selected user's two-factor authentication, for when they have lost access to it.
+func (ch *CommandHandler) handleResetTwoFactor() error {
+	user, err := ch.userSelectMenu()
+	if err != nil {
+		return err
+	}
+
+	if user.ID == ch.crmHandler.LoggedInUser().ID {
+		return wrapError(errNoSelfTwoFactorReset)
+	}
+
+	err = ch.crmHandler.ResetTwoFactor(user.ID, ch.crmHandler.LoggedInUser().ID)
+	if err != nil {
+		return wrapError(err)
+	}
+
+	return nil
+}
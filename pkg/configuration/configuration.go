@@ -1,20 +1,102 @@
 package configuration
 
-import filehandler "work-mini-project/pkg/fileHandler"
+import (
+	filehandler "work-mini-project/pkg/fileHandler"
+	geohandler "work-mini-project/pkg/geoHandler"
+)
 
 type CustomerConfig struct {
 	FilePath string `json:"filePath"`
 }
 
+type DeliveriesConfig struct {
+	FilePath string `json:"filePath"`
+}
+
 type CompanyConfig struct {
-	GridX int `json:"gridX"`
-	GridY int `json:"gridY"`
+	GridX       int                     `json:"gridX"`
+	GridY       int                     `json:"gridY"`
+	Coordinates *geohandler.Coordinates `json:"coordinates,omitempty"` // Depot location, for real-world distances
 }
 
 type UsersConfig struct {
 	FilePath string `json:"filePath"`
 }
 
+// RoleConfig defines a user role as the set of permissions it grants, e.g. "customers:read".
+type RoleConfig struct {
+	Name        string   `json:"name"`
+	Permissions []string `json:"permissions"`
+}
+
+// LockoutConfig limits failed logins. Lockout is disabled if maxFailedAttempts is 0.
+type LockoutConfig struct {
+	MaxFailedAttempts  int    `json:"maxFailedAttempts"`
+	BaseLockoutSeconds int    `json:"baseLockoutSeconds"` // Doubled for each failure past the maximum
+	MaxLockoutSeconds  int    `json:"maxLockoutSeconds"`
+	LogFilePath        string `json:"logFilePath"` // Lockout events are appended here, if set
+}
+
+// RegistrationConfig controls who can register. In "open" mode anyone can register, but must be approved by an
+// administrator before logging in. In "invite" mode an invite code from an administrator is needed instead.
+type RegistrationConfig struct {
+	Mode             string `json:"mode"` // "open" or "invite"
+	InviteExpiryDays int    `json:"inviteExpiryDays"`
+}
+
+// AuditConfig sets where the audit log of every change and login is appended. Auditing is disabled if blank.
+// Entries are also signed with an HMAC if a key is configured, read like the encryption key.
+type AuditConfig struct {
+	FilePath        string `json:"filePath"`
+	HMACKeyEnvVar   string `json:"hmacKeyEnvVar"`
+	HMACKeyFilePath string `json:"hmacKeyFilePath"`
+}
+
+// SessionConfig limits how long users stay logged in. Either limit is disabled if 0.
+type SessionConfig struct {
+	IdleTimeoutMinutes int `json:"idleTimeoutMinutes"` // Logged out after this long without input
+	MaxLifetimeMinutes int `json:"maxLifetimeMinutes"` // Must log in again after this long, however active
+}
+
+// TwoFactorConfig controls TOTP two-factor authentication, which users can opt in to.
+type TwoFactorConfig struct {
+	Issuer            string   `json:"issuer"`            // Account name shown in authenticator apps
+	RequiredRoles     []string `json:"requiredRoles"`     // Roles that must enrol before they can log in
+	RecoveryCodeCount int      `json:"recoveryCodeCount"` // Recovery codes issued on enrolment
+}
+
+// PasswordPolicy is enforced whenever a password is set. A maxLength of 0 means no maximum.
+type PasswordPolicy struct {
+	MinLength               int    `json:"minLength"`
+	MaxLength               int    `json:"maxLength"`
+	RequireUpper            bool   `json:"requireUpper"`
+	RequireLower            bool   `json:"requireLower"`
+	RequireDigit            bool   `json:"requireDigit"`
+	RequireSymbol           bool   `json:"requireSymbol"`
+	BannedPasswordsFilePath string `json:"bannedPasswordsFilePath"` // Optional list of passwords, one per line
+}
+
+// PasswordHashingConfig selects how new passwords are hashed. Existing hashes of either scheme still verify,
+// and are rehashed with the configured scheme and parameters at the user's next login.
+type PasswordHashingConfig struct {
+	Scheme     string `json:"scheme"` // "bcrypt" or "argon2id"
+	BcryptCost int    `json:"bcryptCost"`
+	Argon2     struct {
+		MemoryKiB   uint32 `json:"memoryKiB"`
+		Iterations  uint32 `json:"iterations"`
+		Parallelism uint8  `json:"parallelism"`
+	} `json:"argon2"`
+}
+
+// UsernamePolicy is enforced when registering. Usernames are always unique, ignoring case.
+// A maxLength of 0 means no maximum.
+type UsernamePolicy struct {
+	MinLength          int    `json:"minLength"`
+	MaxLength          int    `json:"maxLength"`
+	AllowedPattern     string `json:"allowedPattern"`     // Optional regular expression usernames must match
+	AllowedDescription string `json:"allowedDescription"` // Shown when a username doesn't match the pattern
+}
+
 type GridLimitsConfig struct {
 	MinX int `json:"minX"`
 	MaxX int `json:"maxX"`
@@ -22,6 +104,13 @@ type GridLimitsConfig struct {
 	MaxY int `json:"maxY"`
 }
 
+// RegionConfig defines a named area of the grid, as either a rectangle or a polygon of [x, y] vertices.
+type RegionConfig struct {
+	Name      string            `json:"name"`
+	Rectangle *GridLimitsConfig `json:"rectangle,omitempty"`
+	Polygon   [][2]int          `json:"polygon,omitempty"`
+}
+
 type VehiclesConfig struct {
 	Lorry struct {
 		Speed                 int `json:"speed"`
@@ -37,12 +126,50 @@ type VehiclesConfig struct {
 	} `json:"helicopter"`
 }
 
+// ProjectionConfig maps real-world coordinates to the grid, with the origin at grid cell (0, 0).
+// Projection is disabled if kilometresPerUnit is 0.
+type ProjectionConfig struct {
+	Origin            geohandler.Coordinates `json:"origin"`
+	KilometresPerUnit float64                `json:"kilometresPerUnit"`
+	PostcodesFilePath string                 `json:"postcodesFilePath"` // Optional CSV of postcode, latitude, longitude
+}
+
+// MapConfig controls how grid maps are drawn in the terminal.
+type MapConfig struct {
+	ASCII bool `json:"ascii"` // Draw with plain ASCII characters, for terminals without Unicode support
+}
+
+type RecycleBinConfig struct {
+	RetentionDays int `json:"retentionDays"`
+}
+
+type EncryptionConfig struct {
+	Enabled     bool   `json:"enabled"`
+	KeyEnvVar   string `json:"keyEnvVar"`
+	KeyFilePath string `json:"keyFilePath"`
+}
+
 type Config struct {
-	Customers  CustomerConfig   `json:"customers"`
-	Company    CompanyConfig    `json:"company"`
-	Users      UsersConfig      `json:"users"`
-	GridLimits GridLimitsConfig `json:"gridLimits"`
-	Vehicles   VehiclesConfig   `json:"vehicles"`
+	Customers       CustomerConfig        `json:"customers"`
+	Company         CompanyConfig         `json:"company"`
+	Users           UsersConfig           `json:"users"`
+	Roles           []RoleConfig          `json:"roles"`
+	Registration    RegistrationConfig    `json:"registration"`
+	Lockout         LockoutConfig         `json:"lockout"`
+	Session         SessionConfig         `json:"session"`
+	Audit           AuditConfig           `json:"audit"`
+	TwoFactor       TwoFactorConfig       `json:"twoFactor"`
+	PasswordPolicy  PasswordPolicy        `json:"passwordPolicy"`
+	UsernamePolicy  UsernamePolicy        `json:"usernamePolicy"`
+	PasswordHashing PasswordHashingConfig `json:"passwordHashing"`
+	Deliveries      DeliveriesConfig      `json:"deliveries"`
+	GridLimits      GridLimitsConfig      `json:"gridLimits"`
+	Regions         []RegionConfig        `json:"regions"`
+	Vehicles        VehiclesConfig        `json:"vehicles"`
+	Encryption      EncryptionConfig      `json:"encryption"`
+	RecycleBin      RecycleBinConfig      `json:"recycleBin"`
+	Map             MapConfig             `json:"map"`
+	Projection      ProjectionConfig      `json:"projection"`
 }
 
 func LoadConfig() (*Config, error) {
@@ -53,3 +180,38 @@ func LoadConfig() (*Config, error) {
 
 	return config, err
 }
+
+// DataFileCipher returns the cipher used to read and write data files,
+// or nil if encryption at rest is disabled.
+func (config *Config) DataFileCipher() (*filehandler.Cipher, error) {
+	if !config.Encryption.Enabled {
+		//nolint:nilnil // nil cipher means files are read and written as plaintext
+		return nil, nil
+	}
+
+	return filehandler.LoadCipher(config.Encryption.KeyEnvVar, config.Encryption.KeyFilePath)
+}
+
+func (config *Config) GridProjection() geohandler.Projection {
+	return geohandler.Projection{
+		Origin:            config.Projection.Origin,
+		KilometresPerUnit: config.Projection.KilometresPerUnit,
+	}
+}
+
+// DepotCoordinates returns the real-world location of the depot,
+// from config if set, otherwise from its grid location. Returns nil if projection is disabled.
+func (config *Config) DepotCoordinates() *geohandler.Coordinates {
+	if config.Company.Coordinates != nil {
+		return config.Company.Coordinates
+	}
+
+	projection := config.GridProjection()
+	if !projection.Enabled() {
+		return nil
+	}
+
+	coordinates := projection.FromGrid(config.Company.GridX, config.Company.GridY)
+
+	return &coordinates
+}
@@ -0,0 +1,51 @@
+package crmhandler
+
+import (
+	"time"
+	audithandler "work-mini-project/pkg/auditHandler"
+)
+
+// Fields of a user recorded in the audit log, leaving out credentials.
+type auditedUser struct {
+	Username  string     `json:"username"`
+	Role      string     `json:"role"`
+	Pending   bool       `json:"pending,omitempty"`
+	TwoFactor bool       `json:"twoFactor,omitempty"`
+	DeletedAt *time.Time `json:"deletedAt,omitempty"`
+	DeletedBy string     `json:"deletedBy,omitempty"`
+}
+
+func auditView(user *User) any {
+	// Return an untyped nil, so missing values are left out of the entry
+	if user == nil {
+		return nil
+	}
+
+	return auditedUser{
+		Username:  user.Username,
+		Role:      user.Role,
+		Pending:   user.Pending,
+		TwoFactor: user.HasTwoFactor(),
+		DeletedAt: user.DeletedAt,
+		DeletedBy: user.DeletedBy,
+	}
+}
+
+// Record an action on a user in the audit log, with the user's state before and after the action, if changed.
+// Before is nil for new users, and after is nil for purged users.
+func (crm *CRMHandler) audit(
+	action string, actorID string, detail string, target User, before *User, after *User,
+) error {
+	err := crm.auditLog.Record(audithandler.Entry{
+		ActorID:    actorID,
+		Action:     action,
+		TargetID:   target.ID,
+		TargetName: target.Username,
+		Detail:     detail,
+	}, auditView(before), auditView(after))
+	if err != nil {
+		return wrapError(err)
+	}
+
+	return nil
+}
@@ -0,0 +1,61 @@
+package crmhandler
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"math/big"
+	"strings"
+)
+
+// Single-use codes, such as recovery and invite codes, are shown split into two groups, e.g. ABCDE-FGHJK.
+const (
+	codeLength    = 10
+	codeAlphabet  = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+	codeSeparator = "-"
+)
+
+// Generate a uniformly random integer in [0, n).
+func randomInt(n int) (int, error) {
+	value, err := rand.Int(rand.Reader, big.NewInt(int64(n)))
+	if err != nil {
+		return 0, wrapError(err)
+	}
+
+	return int(value.Int64()), nil
+}
+
+// Generate a string of the given length from random characters of the alphabet.
+func randomString(length int, alphabet string) (string, error) {
+	value := make([]byte, length)
+
+	for i := range value {
+		index, err := randomInt(len(alphabet))
+		if err != nil {
+			return "", err
+		}
+
+		value[i] = alphabet[index]
+	}
+
+	return string(value), nil
+}
+
+// Generate a single-use code, returning the code to show the user and the hash to store.
+func generateCode() (string, string, error) {
+	code, err := randomString(codeLength, codeAlphabet)
+	if err != nil {
+		return "", "", err
+	}
+
+	return code[:codeLength/2] + codeSeparator + code[codeLength/2:], hashCode(code), nil
+}
+
+// Single-use codes are stored as SHA-256 hashes. They are long and random, so don't need a slow password hash.
+// Codes are matched ignoring case, spaces and separators.
+func hashCode(code string) string {
+	normalised := strings.ToUpper(strings.NewReplacer(codeSeparator, "", " ", "").Replace(code))
+	sum := sha256.Sum256([]byte(normalised))
+
+	return hex.EncodeToString(sum[:])
+}
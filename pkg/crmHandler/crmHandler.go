@@ -3,29 +3,69 @@ package crmhandler
 import (
 	"errors"
 	"fmt"
+	"log"
+	"regexp"
 	"slices"
+	"strings"
+	"time"
+	audithandler "work-mini-project/pkg/auditHandler"
 	clihandler "work-mini-project/pkg/cliHandler"
 	"work-mini-project/pkg/configuration"
 	filehandler "work-mini-project/pkg/fileHandler"
-
-	"golang.org/x/crypto/bcrypt"
+	idhandler "work-mini-project/pkg/idHandler"
 )
 
 type User struct {
-	Username     string `json:"username"`
-	PasswordHash string `json:"passwordHash"`
-	Role         string `json:"role"`
+	ID                 string     `json:"id"`
+	Username           string     `json:"username"`
+	PasswordHash       string     `json:"passwordHash"`
+	Role               string     `json:"role"`
+	FailedLogins       int        `json:"failedLogins,omitempty"` // Consecutive failed logins, reset on success
+	LockedUntil        *time.Time `json:"lockedUntil,omitempty"`
+	MustChangePassword bool       `json:"mustChangePassword,omitempty"` // Set by an admin password reset
+	Pending            bool       `json:"pending,omitempty"`            // Registered, awaiting approval
+	TOTPSecret         string     `json:"totpSecret,omitempty"`         // Base32 secret, set once enrolled in 2FA
+	TOTPLastStep       int64      `json:"totpLastStep,omitempty"`       // Last accepted time step, prevents code reuse
+	RecoveryCodes      []string   `json:"recoveryCodes,omitempty"`      // SHA-256 hashes of unused recovery codes
+	DeletedAt          *time.Time `json:"deletedAt,omitempty"`
+	DeletedBy          string     `json:"deletedBy,omitempty"` // ID of the user who deleted the user
 }
 
 type UsersList struct {
-	Users []User `json:"users"`
+	Version int      `json:"version"`
+	Users   []User   `json:"users"`
+	Invites []Invite `json:"invites,omitempty"`
+}
+
+// Migrations upgrade older users stores to the current schema, in version order.
+var Migrations = []filehandler.Migration{
+	{
+		Version:     1,
+		Description: "add schema version to users store",
+		Migrate:     func(map[string]any) error { return nil },
+	},
+	{
+		Version:     2,
+		Description: "assign a unique ID to each user",
+		Migrate: func(data map[string]any) error {
+			return idhandler.AssignMissing(data["users"])
+		},
+	},
 }
 
 type CRMHandler struct {
 	config       *configuration.Config
 	Users        []User
+	Invites      []Invite
 	cliHandler   *clihandler.CLIHandler
-	LoggedInUser *User
+	session      *session
+	lastModified time.Time
+	fileCipher   *filehandler.Cipher
+	securityLog  *log.Logger
+	auditLog     *audithandler.AuditLog
+
+	bannedPasswords map[string]bool
+	usernamePattern *regexp.Regexp
 }
 
 func wrapError(err error) error {
@@ -34,11 +74,15 @@ func wrapError(err error) error {
 
 const (
 	registrationUsernamePrompt = `
-Please provide a username:`
+Please provide a username, or cancel to go back:`
 
 	//nolint:gosec // password as string, not potential password
 	registrationPasswordPrompt = `
-Please provide a password (1-50 characters):`
+Please provide a password, or leave blank to cancel:`
+
+	//nolint:gosec // password as string, not potential password
+	confirmPasswordPrompt = `
+Please confirm the password:`
 
 	loginUsernamePrompt = `
 Enter username:`
@@ -61,18 +105,119 @@ const (
 )
 
 func New(config *configuration.Config, cliHandler *clihandler.CLIHandler) (*CRMHandler, error) {
-	// Parse customers on initialisation
-	users, err := filehandler.ReadFile[UsersList](config.Users.FilePath)
+	fileCipher, err := config.DataFileCipher()
+	if err != nil {
+		return nil, wrapError(err)
+	}
+
+	// Parse users on initialisation
+	users, err := filehandler.ReadVersionedFile[UsersList](config.Users.FilePath, Migrations, fileCipher)
+	if err != nil {
+		return nil, wrapError(err)
+	}
+
+	lastModified, err := filehandler.GetModifiedTime(config.Users.FilePath)
+	if err != nil {
+		return nil, wrapError(err)
+	}
+
+	securityLog, err := openSecurityLog(config.Lockout.LogFilePath)
+	if err != nil {
+		return nil, err
+	}
+
+	bannedPasswords, err := loadBannedPasswords(config.PasswordPolicy.BannedPasswordsFilePath)
+	if err != nil {
+		return nil, err
+	}
+
+	usernamePattern, err := regexp.Compile(config.UsernamePolicy.AllowedPattern)
+	if err != nil {
+		return nil, wrapError(err)
+	}
+
+	auditLog, err := audithandler.New(config)
 	if err != nil {
 		return nil, wrapError(err)
 	}
 
-	return &CRMHandler{
+	crm := &CRMHandler{
 		config:       config,
 		Users:        users.Users,
+		Invites:      users.Invites,
 		cliHandler:   cliHandler,
-		LoggedInUser: nil,
-	}, nil
+		lastModified: lastModified,
+		fileCipher:   fileCipher,
+		securityLog:  securityLog,
+		auditLog:     auditLog,
+
+		bannedPasswords: bannedPasswords,
+		usernamePattern: usernamePattern,
+	}
+
+	err = crm.validateRoles()
+	if err != nil {
+		return nil, err
+	}
+
+	err = crm.validateHashScheme()
+	if err != nil {
+		return nil, err
+	}
+
+	err = crm.validateRegistrationMode()
+	if err != nil {
+		return nil, err
+	}
+
+	// Every input counts as activity, and is checked against the session timeouts
+	cliHandler.SetInputHook(crm.CheckSession)
+
+	return crm, nil
+}
+
+// Reload re-reads the users store if it has been changed on disk since it was last read or written,
+// so users registered by another instance of the app are picked up without restarting.
+func (crm *CRMHandler) Reload() error {
+	modified, err := filehandler.GetModifiedTime(crm.config.Users.FilePath)
+	if err != nil {
+		return wrapError(err)
+	}
+
+	if modified.Equal(crm.lastModified) {
+		return nil
+	}
+
+	users, err := filehandler.ReadVersionedFile[UsersList](crm.config.Users.FilePath, Migrations, crm.fileCipher)
+	if err != nil {
+		return wrapError(err)
+	}
+
+	crm.Users = users.Users
+	crm.Invites = users.Invites
+	crm.lastModified = modified
+
+	return nil
+}
+
+// Write the stored users list to the persistent users store,
+// tracking the new modification time so our own writes don't trigger a reload.
+func (crm *CRMHandler) save() error {
+	err := filehandler.WriteEncryptedFile(crm.config.Users.FilePath, UsersList{
+		Version: filehandler.LatestVersion(Migrations),
+		Users:   crm.Users,
+		Invites: crm.Invites,
+	}, crm.fileCipher)
+	if err != nil {
+		return wrapError(err)
+	}
+
+	crm.lastModified, err = filehandler.GetModifiedTime(crm.config.Users.FilePath)
+	if err != nil {
+		return wrapError(err)
+	}
+
+	return nil
 }
 
 func (crm *CRMHandler) Login() error {
@@ -88,52 +233,203 @@ func (crm *CRMHandler) Login() error {
 
 	user, err := crm.GetUser(username)
 	if err != nil {
+		auditErr := crm.audit(audithandler.ActionLoginFailed, "", "unknown username", User{Username: username}, nil, nil)
+		if auditErr != nil {
+			return auditErr
+		}
+
 		// Local call so don't need to re-wrap
 		return err
 	}
 
+	if user.IsLocked() {
+		crm.securityLog.Printf("login attempt for locked account %q", user.Username)
+
+		err = crm.audit(audithandler.ActionLoginFailed, "", "account locked", user, nil, nil)
+		if err != nil {
+			return err
+		}
+
+		return lockedError(user)
+	}
+
 	passwordValid := verifyPassword(password, user.PasswordHash)
 	if !passwordValid {
+		err = crm.recordFailedLogin(user.ID, "incorrect password")
+		if err != nil {
+			return err
+		}
+
 		return errIncorrectCredentials
 	}
 
-	crm.LoggedInUser = &user
+	if user.Pending {
+		err = crm.audit(audithandler.ActionLoginFailed, "", "awaiting approval", user, nil, nil)
+		if err != nil {
+			return err
+		}
+
+		return errAccountPending
+	}
+
+	// A wrong authentication code counts towards lockout in the same way as a wrong password
+	if user.HasTwoFactor() {
+		err = crm.verifyTwoFactor(user)
+		if errors.Is(err, errIncorrectTwoFactorCode) {
+			err = crm.recordFailedLogin(user.ID, "incorrect authentication code")
+			if err != nil {
+				return err
+			}
+
+			return errIncorrectTwoFactorCode
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	err = crm.resetFailedLogins(user.ID)
+	if err != nil {
+		return err
+	}
+
+	// Upgrade hashes made with an older scheme or weaker parameters, now the password is known
+	if crm.needsRehash(user.PasswordHash) {
+		err = crm.rehashPassword(user.ID, password)
+		if err != nil {
+			return err
+		}
+	}
+
+	if user.MustChangePassword {
+		crm.cliHandler.WriteOutput("\nYour password was reset, please choose a new password")
+
+		err = crm.promptPasswordChange(user)
+		if err != nil {
+			return err
+		}
+	}
+
+	if !user.HasTwoFactor() && crm.RequiresTwoFactor(user) {
+		crm.cliHandler.WriteOutput("\nTwo-factor authentication is required for your role, please enable it now")
+
+		err = crm.EnrolTwoFactor(user.ID)
+		if errors.Is(err, errTwoFactorNotEnrolled) {
+			return errTwoFactorRequired
+		}
+
+		if err != nil {
+			return err
+		}
+
+		// Give the user a chance to note their recovery codes before the screen is cleared
+		_, err = crm.cliHandler.GetUserInput("Press any key to continue...")
+		if err != nil {
+			return wrapError(err)
+		}
+	}
+
+	err = crm.audit(audithandler.ActionLogin, user.ID, "", user, nil, nil)
+	if err != nil {
+		return err
+	}
+
+	crm.startSession(user)
 
 	crm.cliHandler.WriteOutput("Successfully logged in!")
 
 	return nil
 }
 
+// RegisterAccount prompts for a new account's details. When registration is invite-only a valid invite code
+// is required first, otherwise the account must be approved by an administrator before it can log in.
 func (crm *CRMHandler) RegisterAccount() error {
-	username, err := crm.cliHandler.GetUserInput(registrationUsernamePrompt)
+	inviteHash := ""
+
+	if crm.InviteOnly() {
+		var err error
+
+		inviteHash, err = crm.promptInvite()
+		if err != nil {
+			return err
+		}
+	}
+
+	username, err := crm.promptUsername()
 	if err != nil {
-		return wrapError(err)
+		return err
 	}
 
-	password, err := crm.cliHandler.GetSensitiveInput(registrationPasswordPrompt)
+	password, err := crm.PromptNewPassword(registrationPasswordPrompt, username)
 	if err != nil {
-		return wrapError(err)
+		return err
 	}
 
-	passwordHash, err := hashPassword(password)
+	passwordHash, err := crm.hashPassword(password)
 	if err != nil {
 		return wrapError(err)
 	}
 
-	err = crm.AddUser(User{Username: username, PasswordHash: passwordHash, Role: "user"})
+	// Check the invite is still valid before creating the account, in case it was used while entering details
+	if inviteHash != "" {
+		err = crm.consumeInvite(inviteHash)
+		if err != nil {
+			return err
+		}
+	}
+
+	pending := inviteHash == ""
+
+	// Registered users add themselves, so there is no other user to record as adding them
+	err = crm.AddUser(User{Username: username, PasswordHash: passwordHash, Role: string(USER), Pending: pending}, "")
 	if err != nil {
 		return wrapError(err)
 	}
 
-	crm.cliHandler.WriteOutput("Successfully created new account, please login to continue")
+	if pending {
+		crm.securityLog.Printf("registration pending approval for %q", username)
+		crm.cliHandler.WriteOutput(
+			"Successfully registered, your account must be approved by an administrator before you can log in",
+		)
+	} else {
+		crm.securityLog.Printf("registered %q with an invite code", username)
+		crm.cliHandler.WriteOutput("Successfully created new account, please login to continue")
+	}
 
 	//nolint:err113 // Return empty error to restart app to prompt for log in
 	return errors.New("")
 }
 
+// Find the index of the user with the given ID in the stored users list.
+func (crm *CRMHandler) indexOf(id string) int {
+	return slices.IndexFunc(crm.Users, func(E User) bool {
+		return E.ID == id
+	})
+}
+
+func (crm *CRMHandler) GetUserByID(id string) (User, error) {
+	err := crm.Reload()
+	if err != nil {
+		return User{}, err
+	}
+
+	userIdx := crm.indexOf(id)
+	if userIdx == -1 {
+		return User{}, wrapError(errUserNotFound)
+	}
+
+	return crm.Users[userIdx], nil
+}
+
 func (crm *CRMHandler) GetUser(username string) (User, error) {
+	err := crm.Reload()
+	if err != nil {
+		return User{}, err
+	}
+
 	userIdx := slices.IndexFunc(crm.Users, func(E User) bool {
-		return E.Username == username
+		return strings.EqualFold(E.Username, username) && !E.IsDeleted()
 	})
 
 	if userIdx == -1 {
@@ -143,78 +439,96 @@ func (crm *CRMHandler) GetUser(username string) (User, error) {
 	return crm.Users[userIdx], nil
 }
 
-func (crm *CRMHandler) AddUser(user User) error {
-	// Check username is unique
+func (crm *CRMHandler) AddUser(user User, addedBy string) error {
+	// Pick up any changes from other processes before modifying the store
+	err := crm.Reload()
+	if err != nil {
+		return err
+	}
+
+	// Check username is unique, ignoring case
 	usernameMatchIdx := slices.IndexFunc(crm.Users, func(E User) bool {
-		return E.Username == user.Username
+		return strings.EqualFold(E.Username, user.Username) && !E.IsDeleted()
 	})
 	if usernameMatchIdx != -1 {
 		return wrapError(errUserAlreadyExists)
 	}
 
+	// Assign an immutable ID, used to reference the user from other records
+	user.ID, err = idhandler.Generate()
+	if err != nil {
+		return wrapError(err)
+	}
+
 	// Update stored users list
 	crm.Users = append(crm.Users, user)
 
-	// Update persistent customer store
-	err := filehandler.WriteFile(crm.config.Users.FilePath, UsersList{Users: crm.Users})
+	// Update persistent users store
+	err = crm.save()
 	if err != nil {
-		return wrapError(err)
+		return err
 	}
 
-	return nil
+	return crm.audit(audithandler.ActionUserAdd, addedBy, "", user, nil, &user)
 }
 
-func (crm *CRMHandler) RemoveUser(user User) error {
+// RemoveUser moves the user to the recycle bin, recording when and by whom it was deleted.
+// It can be restored, or purged once the retention period has passed.
+func (crm *CRMHandler) RemoveUser(id string, deletedBy string) error {
+	// Pick up any changes from other processes before modifying the store
+	err := crm.Reload()
+	if err != nil {
+		return err
+	}
+
 	// Find index of user in stored list
-	index := slices.Index(crm.Users, user)
-	if index == -1 {
+	index := crm.indexOf(id)
+	if index == -1 || crm.Users[index].IsDeleted() {
 		return wrapError(errUserNotFound)
 	}
 
-	// Crop the user out of the stored users list
-	crm.Users = append(crm.Users[:index], crm.Users[index+1:]...)
+	before := crm.Users[index]
+
+	deletedAt := time.Now()
+	crm.Users[index].DeletedAt = &deletedAt
+	crm.Users[index].DeletedBy = deletedBy
 
 	// Update persistent users store
-	err := filehandler.WriteFile(crm.config.Users.FilePath, UsersList{Users: crm.Users})
+	err = crm.save()
 	if err != nil {
-		return wrapError(err)
+		return err
 	}
 
-	return nil
+	return crm.audit(audithandler.ActionUserRemove, deletedBy, "", before, &before, &crm.Users[index])
 }
 
-func (crm *CRMHandler) SetUserRole(user User, role AccountRole) error {
+func (crm *CRMHandler) SetUserRole(id string, role AccountRole, changedBy string) error {
+	// Pick up any changes from other processes before modifying the store
+	err := crm.Reload()
+	if err != nil {
+		return err
+	}
+
+	if !slices.Contains(crm.RoleNames(), role) {
+		return wrapError(errUnknownRole)
+	}
+
 	// Find index of user in stored list
-	index := slices.Index(crm.Users, user)
-	if index == -1 {
+	index := crm.indexOf(id)
+	if index == -1 || crm.Users[index].IsDeleted() {
 		return wrapError(errUserNotFound)
 	}
 
+	before := crm.Users[index]
+
 	// Update stored users list
 	crm.Users[index].Role = string(role)
 
 	// Update persistent users store
-	err := filehandler.WriteFile(crm.config.Users.FilePath, UsersList{Users: crm.Users})
+	err = crm.save()
 	if err != nil {
-		return wrapError(err)
+		return err
 	}
 
-	return nil
-}
-
-// HashPassword generates a bcrypt hash for the given password.
-func hashPassword(password string) (string, error) {
-	// If changed, passwords will invalidate
-	passwordCost := 4
-
-	bytes, err := bcrypt.GenerateFromPassword([]byte(password), passwordCost)
-
-	return string(bytes), err
-}
-
-// VerifyPassword verifies if the given password matches the stored hash.
-func verifyPassword(password, hash string) bool {
-	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
-
-	return err == nil
+	return crm.audit(audithandler.ActionUserRole, changedBy, "", before, &before, &crm.Users[index])
 }
@@ -0,0 +1,205 @@
+package crmhandler
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Password hashing schemes, selected by passwordHashing.scheme in config.
+const (
+	SchemeBcrypt   = "bcrypt"
+	SchemeArgon2id = "argon2id"
+)
+
+const (
+	argon2SaltLength = 16
+	argon2KeyLength  = 32
+)
+
+// Argon2id hashes are stored in PHC string format, e.g. $argon2id$v=19$m=65536,t=3,p=2$<salt>$<hash>.
+const argon2idPrefix = "$argon2id$"
+
+var errUnknownHashScheme = errors.New("password hashing scheme must be bcrypt or argon2id")
+
+var errInvalidArgon2Hash = errors.New("invalid argon2id password hash")
+
+var errInvalidArgon2Params = errors.New("argon2 memoryKiB, iterations and parallelism must each be at least 1")
+
+type argon2Params struct {
+	memory      uint32 // KiB
+	iterations  uint32
+	parallelism uint8
+}
+
+// argon2.IDKey panics with fewer than one iteration or thread, so parameters are checked before use.
+func (params argon2Params) valid() bool {
+	return params.memory >= 1 && params.iterations >= 1 && params.parallelism >= 1
+}
+
+// The configured hashing scheme. Configs from before the scheme was configurable have none, and used bcrypt.
+func (crm *CRMHandler) hashScheme() string {
+	if crm.config.PasswordHashing.Scheme == "" {
+		return SchemeBcrypt
+	}
+
+	return crm.config.PasswordHashing.Scheme
+}
+
+func (crm *CRMHandler) configuredArgon2Params() argon2Params {
+	return argon2Params{
+		memory:      crm.config.PasswordHashing.Argon2.MemoryKiB,
+		iterations:  crm.config.PasswordHashing.Argon2.Iterations,
+		parallelism: crm.config.PasswordHashing.Argon2.Parallelism,
+	}
+}
+
+// Check the configured hashing scheme is supported, and its parameters are usable.
+func (crm *CRMHandler) validateHashScheme() error {
+	switch crm.hashScheme() {
+	case SchemeBcrypt:
+		return nil
+	case SchemeArgon2id:
+		if !crm.configuredArgon2Params().valid() {
+			return wrapError(errInvalidArgon2Params)
+		}
+
+		return nil
+	default:
+		return wrapError(errUnknownHashScheme)
+	}
+}
+
+// Hash a password with the configured scheme and parameters.
+func (crm *CRMHandler) hashPassword(password string) (string, error) {
+	if crm.hashScheme() == SchemeArgon2id {
+		return hashArgon2id(password, crm.configuredArgon2Params())
+	}
+
+	// bcrypt stores its cost in the hash, so changing the cost doesn't invalidate existing passwords
+	bytes, err := bcrypt.GenerateFromPassword([]byte(password), crm.config.PasswordHashing.BcryptCost)
+	if err != nil {
+		return "", wrapError(err)
+	}
+
+	return string(bytes), nil
+}
+
+// Report whether a hash was made with a different scheme to the configured one, or weaker parameters.
+func (crm *CRMHandler) needsRehash(hash string) bool {
+	if strings.HasPrefix(hash, argon2idPrefix) {
+		if crm.hashScheme() != SchemeArgon2id {
+			return true
+		}
+
+		params, _, _, err := parseArgon2id(hash)
+
+		return err != nil || params != crm.configuredArgon2Params()
+	}
+
+	if crm.hashScheme() != SchemeBcrypt {
+		return true
+	}
+
+	cost, err := bcrypt.Cost([]byte(hash))
+
+	return err != nil || cost < crm.config.PasswordHashing.BcryptCost
+}
+
+// Replace a user's password hash with one made using the configured scheme, leaving the rest of the user unchanged.
+func (crm *CRMHandler) rehashPassword(id string, password string) error {
+	passwordHash, err := crm.hashPassword(password)
+	if err != nil {
+		return err
+	}
+
+	err = crm.Reload()
+	if err != nil {
+		return err
+	}
+
+	index := crm.indexOf(id)
+	if index == -1 {
+		return wrapError(errUserNotFound)
+	}
+
+	crm.Users[index].PasswordHash = passwordHash
+
+	return crm.save()
+}
+
+// Verify a password against a bcrypt or argon2id hash, whichever the hash was made with.
+func verifyPassword(password, hash string) bool {
+	if strings.HasPrefix(hash, argon2idPrefix) {
+		params, salt, key, err := parseArgon2id(hash)
+		if err != nil {
+			return false
+		}
+
+		derived := argon2.IDKey([]byte(password), salt, params.iterations, params.memory, params.parallelism,
+			uint32(len(key))) //nolint:gosec // key length is always small
+
+		return subtle.ConstantTimeCompare(derived, key) == 1
+	}
+
+	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+
+	return err == nil
+}
+
+func hashArgon2id(password string, params argon2Params) (string, error) {
+	salt := make([]byte, argon2SaltLength)
+
+	_, err := rand.Read(salt)
+	if err != nil {
+		return "", wrapError(err)
+	}
+
+	key := argon2.IDKey([]byte(password), salt, params.iterations, params.memory, params.parallelism, argon2KeyLength)
+
+	return fmt.Sprintf("%sv=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2idPrefix, argon2.Version, params.memory, params.iterations, params.parallelism,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+// Parse an argon2id hash in PHC string format into its parameters, salt and derived key.
+func parseArgon2id(hash string) (argon2Params, []byte, []byte, error) {
+	var params argon2Params
+
+	var version int
+
+	// Fields are "", "argon2id", "v=19", "m=...,t=...,p=...", salt, key
+	fields := strings.Split(hash, "$")
+	if len(fields) != 6 {
+		return params, nil, nil, wrapError(errInvalidArgon2Hash)
+	}
+
+	_, err := fmt.Sscanf(fields[2], "v=%d", &version)
+	if err != nil || version != argon2.Version {
+		return params, nil, nil, wrapError(errInvalidArgon2Hash)
+	}
+
+	_, err = fmt.Sscanf(fields[3], "m=%d,t=%d,p=%d", &params.memory, &params.iterations, &params.parallelism)
+	if err != nil || !params.valid() {
+		return params, nil, nil, wrapError(errInvalidArgon2Hash)
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(fields[4])
+	if err != nil {
+		return params, nil, nil, wrapError(errInvalidArgon2Hash)
+	}
+
+	key, err := base64.RawStdEncoding.DecodeString(fields[5])
+	if err != nil || len(key) == 0 {
+		return params, nil, nil, wrapError(errInvalidArgon2Hash)
+	}
+
+	return params, salt, key, nil
+}
@@ -0,0 +1,136 @@
+package crmhandler
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"work-mini-project/pkg/configuration"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Small argon2 parameters, so tests run quickly.
+var testArgon2Params = argon2Params{memory: 64, iterations: 1, parallelism: 1}
+
+func mustHashArgon2id(t *testing.T, password string, params argon2Params) string {
+	t.Helper()
+
+	hash, err := hashArgon2id(password, params)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return hash
+}
+
+func mustHashBcrypt(t *testing.T, password string, cost int) string {
+	t.Helper()
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), cost)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return string(hash)
+}
+
+func TestParseArgon2id(t *testing.T) {
+	hash := mustHashArgon2id(t, "password", testArgon2Params)
+	fields := strings.Split(hash, "$")
+
+	params, salt, key, err := parseArgon2id(hash)
+	if err != nil || params != testArgon2Params || len(salt) != argon2SaltLength || len(key) != argon2KeyLength {
+		t.Fatalf("parseArgon2id() = %+v, %d byte salt, %d byte key, %v", params, len(salt), len(key), err)
+	}
+
+	// Replace one field of the valid hash
+	withField := func(index int, value string) string {
+		changed := append([]string{}, fields...)
+		changed[index] = value
+
+		return strings.Join(changed, "$")
+	}
+
+	tests := []struct {
+		name string
+		hash string
+	}{
+		{"too few fields", strings.Join(fields[:5], "$")},
+		{"too many fields", hash + "$extra"},
+		{"other version", withField(2, "v=16")},
+		{"malformed version", withField(2, "version=19")},
+		{"zero memory", withField(3, "m=0,t=1,p=1")},
+		{"zero iterations", withField(3, "m=64,t=0,p=1")},
+		{"zero parallelism", withField(3, "m=64,t=1,p=0")},
+		{"malformed parameters", withField(3, "t=1,m=64,p=1")},
+		{"salt not base64", withField(4, "not base64!")},
+		{"key not base64", withField(5, "not base64!")},
+		{"empty key", withField(5, "")},
+	}
+
+	for _, test := range tests {
+		_, _, _, err := parseArgon2id(test.hash)
+		if !errors.Is(err, errInvalidArgon2Hash) {
+			t.Errorf("%s: parseArgon2id() error = %v, want %v", test.name, err, errInvalidArgon2Hash)
+		}
+	}
+}
+
+func TestNeedsRehash(t *testing.T) {
+	bcryptConfig := configuration.PasswordHashingConfig{Scheme: SchemeBcrypt, BcryptCost: 6}
+	legacyConfig := configuration.PasswordHashingConfig{BcryptCost: 6} // From before the scheme was configurable
+
+	argon2Config := configuration.PasswordHashingConfig{Scheme: SchemeArgon2id}
+	argon2Config.Argon2.MemoryKiB = testArgon2Params.memory
+	argon2Config.Argon2.Iterations = testArgon2Params.iterations
+	argon2Config.Argon2.Parallelism = testArgon2Params.parallelism
+
+	weakerArgon2Params := testArgon2Params
+	weakerArgon2Params.memory /= 2
+
+	bcryptHash := mustHashBcrypt(t, "password", 6)
+	argon2Hash := mustHashArgon2id(t, "password", testArgon2Params)
+
+	tests := []struct {
+		name    string
+		hashing configuration.PasswordHashingConfig
+		hash    string
+		want    bool
+	}{
+		{"bcrypt at the configured cost", bcryptConfig, bcryptHash, false},
+		{"bcrypt above the configured cost", bcryptConfig, mustHashBcrypt(t, "password", 7), false},
+		{"bcrypt below the configured cost", bcryptConfig, mustHashBcrypt(t, "password", 4), true},
+		{"bcrypt with no scheme configured", legacyConfig, bcryptHash, false},
+		{"argon2id when bcrypt is configured", bcryptConfig, argon2Hash, true},
+		{"argon2id with the configured parameters", argon2Config, argon2Hash, false},
+		{"argon2id with other parameters", argon2Config, mustHashArgon2id(t, "password", weakerArgon2Params), true},
+		{"bcrypt when argon2id is configured", argon2Config, bcryptHash, true},
+		{"malformed argon2id", argon2Config, argon2idPrefix + "v=19", true},
+		{"malformed bcrypt", bcryptConfig, "not a hash", true},
+	}
+
+	for _, test := range tests {
+		crm := &CRMHandler{config: &configuration.Config{PasswordHashing: test.hashing}}
+
+		if got := crm.needsRehash(test.hash); got != test.want {
+			t.Errorf("%s: needsRehash() = %t, want %t", test.name, got, test.want)
+		}
+	}
+}
+
+func TestVerifyPassword(t *testing.T) {
+	hashes := map[string]string{
+		SchemeBcrypt:   mustHashBcrypt(t, "correct horse", bcrypt.MinCost),
+		SchemeArgon2id: mustHashArgon2id(t, "correct horse", testArgon2Params),
+	}
+
+	for scheme, hash := range hashes {
+		if !verifyPassword("correct horse", hash) {
+			t.Errorf("%s: verifyPassword() rejected the correct password", scheme)
+		}
+
+		if verifyPassword("Correct horse", hash) {
+			t.Errorf("%s: verifyPassword() accepted an incorrect password", scheme)
+		}
+	}
+}
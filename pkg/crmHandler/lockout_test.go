@@ -0,0 +1,42 @@
+package crmhandler
+
+import (
+	"testing"
+	"time"
+	"work-mini-project/pkg/configuration"
+)
+
+func TestLockoutDuration(t *testing.T) {
+	lockout := configuration.LockoutConfig{MaxFailedAttempts: 3, BaseLockoutSeconds: 30, MaxLockoutSeconds: 300}
+
+	tests := []struct {
+		name         string
+		lockout      configuration.LockoutConfig
+		failedLogins int
+		want         time.Duration
+	}{
+		{"no failures", lockout, 0, 0},
+		{"below the maximum attempts", lockout, 2, 0},
+		{"at the maximum attempts", lockout, 3, 30 * time.Second},
+		{"doubled once", lockout, 4, time.Minute},
+		{"doubled twice", lockout, 5, 2 * time.Minute},
+		{"doubled three times", lockout, 6, 4 * time.Minute},
+		{"capped at the maximum lockout", lockout, 7, 5 * time.Minute},
+		{"capped without overflowing", lockout, 1000, 5 * time.Minute},
+		{
+			"base above the maximum lockout",
+			configuration.LockoutConfig{MaxFailedAttempts: 1, BaseLockoutSeconds: 600, MaxLockoutSeconds: 300},
+			1,
+			5 * time.Minute,
+		},
+		{"disabled", configuration.LockoutConfig{BaseLockoutSeconds: 30, MaxLockoutSeconds: 300}, 10, 0},
+	}
+
+	for _, test := range tests {
+		crm := &CRMHandler{config: &configuration.Config{Lockout: test.lockout}}
+
+		if got := crm.lockoutDuration(test.failedLogins); got != test.want {
+			t.Errorf("%s: lockoutDuration(%d) = %v, want %v", test.name, test.failedLogins, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,221 @@
+package crmhandler
+
+import (
+	"errors"
+	audithandler "work-mini-project/pkg/auditHandler"
+)
+
+// Length of temporary passwords issued by a password reset, unless the password policy needs them longer.
+const temporaryPasswordLength = 12
+
+// Characters used in temporary passwords, leaving out those easily confused with each other.
+// Symbols are only used when the password policy requires one.
+const (
+	temporaryPasswordUpper   = "ABCDEFGHJKLMNPQRSTUVWXYZ"
+	temporaryPasswordLower   = "abcdefghijkmnopqrstuvwxyz"
+	temporaryPasswordDigits  = "23456789"
+	temporaryPasswordSymbols = "!#%+-=?@"
+)
+
+var errSamePassword = errors.New("new password must be different to the current password")
+
+//nolint:gosec // password as string, not potential password
+const (
+	currentPasswordPrompt = `
+Enter current password, or leave blank to cancel:`
+
+	newPasswordPrompt = `
+Please provide a new password, or leave blank to cancel:`
+)
+
+// Replace a user's password hash, clearing any lockout, and set whether it must be changed at next login.
+func (crm *CRMHandler) setPassword(id string, password string, mustChange bool) error {
+	passwordHash, err := crm.hashPassword(password)
+	if err != nil {
+		return wrapError(err)
+	}
+
+	err = crm.Reload()
+	if err != nil {
+		return err
+	}
+
+	index := crm.indexOf(id)
+	if index == -1 || crm.Users[index].IsDeleted() {
+		return wrapError(errUserNotFound)
+	}
+
+	crm.Users[index].PasswordHash = passwordHash
+	crm.Users[index].MustChangePassword = mustChange
+	crm.Users[index].FailedLogins = 0
+	crm.Users[index].LockedUntil = nil
+
+	return crm.save()
+}
+
+// ChangePassword prompts the logged in user for their current password, then a new password meeting the policy.
+// Leaving either blank, or entering cancel, changes nothing.
+func (crm *CRMHandler) ChangePassword() error {
+	user, err := crm.GetUserByID(crm.LoggedInUser().ID)
+	if err != nil {
+		return err
+	}
+
+	currentPassword, err := crm.cliHandler.GetSensitiveInput(currentPasswordPrompt)
+	if err != nil {
+		return wrapError(err)
+	}
+
+	// Cancelling isn't a failed attempt, so it doesn't count towards lockout
+	if currentPassword == "" {
+		return wrapError(errCancelled)
+	}
+
+	err = checkForKeywords(currentPassword)
+	if err != nil {
+		return err
+	}
+
+	if !verifyPassword(currentPassword, user.PasswordHash) {
+		return crm.failPasswordChange(user.ID)
+	}
+
+	return crm.promptPasswordChange(user)
+}
+
+// Count a wrong current password as a failed login, so it can't be guessed from an unattended terminal.
+// The session ends if the account is locked out.
+func (crm *CRMHandler) failPasswordChange(id string) error {
+	err := crm.recordFailedLogin(id, "incorrect current password")
+	if err != nil {
+		return err
+	}
+
+	user, err := crm.GetUserByID(id)
+	if err != nil {
+		return err
+	}
+
+	if !user.IsLocked() {
+		return errIncorrectCredentials
+	}
+
+	err = crm.endSession("account locked")
+	if err != nil {
+		return err
+	}
+
+	return lockedError(user)
+}
+
+// Prompt for a new password, different to the user's current one, and store it.
+func (crm *CRMHandler) promptPasswordChange(user User) error {
+	for {
+		password, err := crm.PromptNewPassword(newPasswordPrompt, user.Username)
+		if err != nil {
+			return err
+		}
+
+		if verifyPassword(password, user.PasswordHash) {
+			crm.cliHandler.WriteOutput(errSamePassword.Error())
+
+			continue
+		}
+
+		err = crm.setPassword(user.ID, password, false)
+		if err != nil {
+			return err
+		}
+
+		crm.securityLog.Printf("password changed for %q", user.Username)
+		crm.cliHandler.WriteOutput("Password changed")
+
+		return crm.audit(audithandler.ActionPasswordChange, user.ID, "", user, nil, nil)
+	}
+}
+
+// ResetPassword replaces a user's password with a random temporary password, which must be changed at next login.
+// Returns the temporary password, to be given to the user.
+func (crm *CRMHandler) ResetPassword(id string, resetBy string) (string, error) {
+	user, err := crm.GetUserByID(id)
+	if err != nil {
+		return "", err
+	}
+
+	password, err := crm.temporaryPassword()
+	if err != nil {
+		return "", err
+	}
+
+	err = crm.setPassword(id, password, true)
+	if err != nil {
+		return "", err
+	}
+
+	crm.securityLog.Printf("password reset for %q, by user ID %s", user.Username, resetBy)
+
+	err = crm.audit(audithandler.ActionPasswordReset, resetBy, "", user, nil, nil)
+	if err != nil {
+		return "", err
+	}
+
+	return password, nil
+}
+
+// Generate a random temporary password meeting the password policy's length and character requirements,
+// with at least one character from each required class.
+func (crm *CRMHandler) temporaryPassword() (string, error) {
+	policy := crm.config.PasswordPolicy
+
+	length := max(temporaryPasswordLength, policy.MinLength)
+	if policy.MaxLength > 0 {
+		length = min(length, policy.MaxLength)
+	}
+
+	alphabet := temporaryPasswordUpper + temporaryPasswordLower + temporaryPasswordDigits
+	if policy.RequireSymbol {
+		alphabet += temporaryPasswordSymbols
+	}
+
+	password := []byte{}
+
+	for _, class := range []struct {
+		required bool
+		alphabet string
+	}{
+		{policy.RequireUpper, temporaryPasswordUpper},
+		{policy.RequireLower, temporaryPasswordLower},
+		{policy.RequireDigit, temporaryPasswordDigits},
+		{policy.RequireSymbol, temporaryPasswordSymbols},
+	} {
+		if !class.required {
+			continue
+		}
+
+		character, err := randomString(1, class.alphabet)
+		if err != nil {
+			return "", err
+		}
+
+		password = append(password, character...)
+	}
+
+	rest, err := randomString(max(length-len(password), 0), alphabet)
+	if err != nil {
+		return "", err
+	}
+
+	password = append(password, rest...)
+
+	// Shuffle, so the required characters aren't always at the start
+	for i := len(password) - 1; i > 0; i-- {
+		j, err := randomInt(i + 1)
+		if err != nil {
+			return "", err
+		}
+
+		password[i], password[j] = password[j], password[i]
+	}
+
+	return string(password), nil
+}
@@ -0,0 +1,92 @@
+package crmhandler
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// Permission allows a user to perform a group of actions. Roles are defined in config as sets of permissions.
+type Permission string
+
+const (
+	PermissionQuotesCreate    Permission = "quotes:create"
+	PermissionDeliveriesBook  Permission = "deliveries:book"
+	PermissionCustomersRead   Permission = "customers:read"
+	PermissionCustomersWrite  Permission = "customers:write"
+	PermissionCustomersExport Permission = "customers:export"
+	PermissionPricingView     Permission = "pricing:view"
+	PermissionPricingWrite    Permission = "pricing:write"
+	PermissionUsersManage     Permission = "users:manage"
+	PermissionRecycleBinPurge Permission = "recycleBin:purge"
+	PermissionAuditView       Permission = "audit:view"
+)
+
+// Permissions lists every permission a role can be granted.
+func Permissions() []Permission {
+	return []Permission{
+		PermissionQuotesCreate, PermissionDeliveriesBook,
+		PermissionCustomersRead, PermissionCustomersWrite, PermissionCustomersExport,
+		PermissionPricingView, PermissionPricingWrite,
+		PermissionUsersManage, PermissionRecycleBinPurge,
+		PermissionAuditView,
+	}
+}
+
+var errUnknownPermission = errors.New("role has an unknown permission")
+
+var errUnknownRole = errors.New("role is not one of the configured roles")
+
+// Check every configured role only grants known permissions.
+func (crm *CRMHandler) validateRoles() error {
+	for _, role := range crm.config.Roles {
+		for _, permission := range role.Permissions {
+			if !slices.Contains(Permissions(), Permission(permission)) {
+				return wrapError(fmt.Errorf("%w: %s has %q", errUnknownPermission, role.Name, permission))
+			}
+		}
+	}
+
+	return nil
+}
+
+// RoleNames returns the names of the configured roles, in config order.
+func (crm *CRMHandler) RoleNames() []AccountRole {
+	names := make([]AccountRole, len(crm.config.Roles))
+	for i, role := range crm.config.Roles {
+		names[i] = AccountRole(role.Name)
+	}
+
+	return names
+}
+
+// HasPermission reports whether the user's role grants the permission. Users with unknown roles have no permissions.
+func (crm *CRMHandler) HasPermission(user *User, permission Permission) bool {
+	if user == nil {
+		return false
+	}
+
+	for _, role := range crm.config.Roles {
+		if role.Name == user.Role {
+			return slices.Contains(role.Permissions, string(permission))
+		}
+	}
+
+	return false
+}
+
+// LoggedInUserHasPermission reports whether the logged in user's role currently grants the permission.
+// The user is re-read from the store, so a role change or removal by another admin applies straight away.
+func (crm *CRMHandler) LoggedInUserHasPermission(permission Permission) bool {
+	loggedInUser := crm.LoggedInUser()
+	if loggedInUser == nil {
+		return false
+	}
+
+	user, err := crm.GetUserByID(loggedInUser.ID)
+	if err != nil || user.IsDeleted() || user.Pending {
+		return false
+	}
+
+	return crm.HasPermission(&user, permission)
+}
@@ -0,0 +1,213 @@
+package crmhandler
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+var errPasswordMismatch = errors.New("passwords did not match, please try again")
+
+var errCancelled = errors.New("cancelled")
+
+// Shown when a username doesn't match the allowed pattern and config doesn't describe it.
+const defaultAllowedDescription = "contains characters that are not allowed"
+
+// Describe a length rule, if the length breaks it. A maximum of 0 means no maximum.
+func lengthProblem(length int, minLength int, maxLength int) string {
+	if maxLength == 0 {
+		if length < minLength {
+			return fmt.Sprintf("must be at least %d characters", minLength)
+		}
+
+		return ""
+	}
+
+	if length < minLength || length > maxLength {
+		return fmt.Sprintf("must be %d-%d characters", minLength, maxLength)
+	}
+
+	return ""
+}
+
+func isSymbol(r rune) bool {
+	return unicode.IsPunct(r) || unicode.IsSymbol(r)
+}
+
+// Load the banned password list, one password per line, lower-cased so matching ignores case.
+func loadBannedPasswords(filePath string) (map[string]bool, error) {
+	banned := map[string]bool{}
+
+	if filePath == "" {
+		return banned, nil
+	}
+
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, wrapError(err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if password := strings.TrimSpace(scanner.Text()); password != "" {
+			banned[strings.ToLower(password)] = true
+		}
+	}
+
+	if err = scanner.Err(); err != nil {
+		return nil, wrapError(err)
+	}
+
+	return banned, nil
+}
+
+// ValidateUsername checks a username against the configured username rules and existing users,
+// returning a description of each rule it breaks.
+func (crm *CRMHandler) ValidateUsername(username string) []string {
+	policy := crm.config.UsernamePolicy
+	problems := []string{}
+
+	if problem := lengthProblem(utf8.RuneCountInString(username), policy.MinLength, policy.MaxLength); problem != "" {
+		problems = append(problems, problem)
+	}
+
+	if !crm.usernamePattern.MatchString(username) {
+		description := policy.AllowedDescription
+		if description == "" {
+			description = defaultAllowedDescription
+		}
+
+		problems = append(problems, description)
+	}
+
+	if _, err := crm.GetUser(username); err == nil {
+		problems = append(problems, "is already taken")
+	}
+
+	return problems
+}
+
+// ValidatePassword checks a password against the configured password policy,
+// returning a description of each rule it breaks.
+func (crm *CRMHandler) ValidatePassword(password string, username string) []string {
+	policy := crm.config.PasswordPolicy
+	problems := []string{}
+
+	if problem := lengthProblem(utf8.RuneCountInString(password), policy.MinLength, policy.MaxLength); problem != "" {
+		problems = append(problems, problem)
+	}
+
+	classes := []struct {
+		required bool
+		matches  func(rune) bool
+		problem  string
+	}{
+		{policy.RequireUpper, unicode.IsUpper, "must contain an upper case letter"},
+		{policy.RequireLower, unicode.IsLower, "must contain a lower case letter"},
+		{policy.RequireDigit, unicode.IsDigit, "must contain a number"},
+		{policy.RequireSymbol, isSymbol, "must contain a symbol"},
+	}
+
+	for _, class := range classes {
+		if class.required && !strings.ContainsFunc(password, class.matches) {
+			problems = append(problems, class.problem)
+		}
+	}
+
+	if username != "" && strings.EqualFold(password, username) {
+		problems = append(problems, "must not be the same as the username")
+	}
+
+	if crm.bannedPasswords[strings.ToLower(password)] {
+		problems = append(problems, "is too common, please choose another")
+	}
+
+	return problems
+}
+
+// Act on the exit and cancel keywords, as the command handler does, at prompts that repeat until the input is valid.
+func checkForKeywords(input string) error {
+	switch strings.ToLower(strings.TrimSpace(input)) {
+	case "exit":
+		os.Exit(0)
+
+	case "cancel":
+		return wrapError(errCancelled)
+	}
+
+	return nil
+}
+
+// Prompt for a username until it meets the username rules.
+func (crm *CRMHandler) promptUsername() (string, error) {
+	for {
+		username, err := crm.cliHandler.GetUserInput(registrationUsernamePrompt)
+		if err != nil {
+			return "", wrapError(err)
+		}
+
+		err = checkForKeywords(username)
+		if err != nil {
+			return "", err
+		}
+
+		username = strings.TrimSpace(username)
+
+		problems := crm.ValidateUsername(username)
+		if len(problems) == 0 {
+			return username, nil
+		}
+
+		crm.cliHandler.WriteOutput("Username " + strings.Join(problems, "\nUsername "))
+	}
+}
+
+// PromptNewPassword prompts for a password until it meets the password policy and is confirmed by entering it again,
+// or the user cancels by leaving it blank or entering cancel.
+func (crm *CRMHandler) PromptNewPassword(prompt string, username string) (string, error) {
+	for {
+		password, err := crm.cliHandler.GetSensitiveInput(prompt)
+		if err != nil {
+			return "", wrapError(err)
+		}
+
+		if password == "" {
+			return "", wrapError(errCancelled)
+		}
+
+		err = checkForKeywords(password)
+		if err != nil {
+			return "", err
+		}
+
+		problems := crm.ValidatePassword(password, username)
+		if len(problems) > 0 {
+			crm.cliHandler.WriteOutput("Password " + strings.Join(problems, "\nPassword "))
+
+			continue
+		}
+
+		confirmation, err := crm.cliHandler.GetSensitiveInput(confirmPasswordPrompt)
+		if err != nil {
+			return "", wrapError(err)
+		}
+
+		err = checkForKeywords(confirmation)
+		if err != nil {
+			return "", err
+		}
+
+		if confirmation != password {
+			crm.cliHandler.WriteOutput(errPasswordMismatch.Error())
+
+			continue
+		}
+
+		return password, nil
+	}
+}
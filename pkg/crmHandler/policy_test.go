@@ -0,0 +1,120 @@
+package crmhandler
+
+import (
+	"path/filepath"
+	"regexp"
+	"slices"
+	"testing"
+	"time"
+	"work-mini-project/pkg/configuration"
+	filehandler "work-mini-project/pkg/fileHandler"
+)
+
+func TestLengthProblem(t *testing.T) {
+	tests := []struct {
+		length    int
+		minLength int
+		maxLength int
+		want      string
+	}{
+		{8, 8, 20, ""},
+		{20, 8, 20, ""},
+		{7, 8, 20, "must be 8-20 characters"},
+		{21, 8, 20, "must be 8-20 characters"},
+		{100, 8, 0, ""},
+		{7, 8, 0, "must be at least 8 characters"},
+	}
+
+	for _, test := range tests {
+		if got := lengthProblem(test.length, test.minLength, test.maxLength); got != test.want {
+			t.Errorf("lengthProblem(%d, %d, %d) = %q, want %q",
+				test.length, test.minLength, test.maxLength, got, test.want)
+		}
+	}
+}
+
+func TestValidatePassword(t *testing.T) {
+	crm := &CRMHandler{
+		config: &configuration.Config{PasswordPolicy: configuration.PasswordPolicy{
+			MinLength: 8, MaxLength: 20, RequireUpper: true, RequireLower: true, RequireDigit: true, RequireSymbol: true,
+		}},
+		bannedPasswords: map[string]bool{"password1!": true},
+	}
+
+	tests := []struct {
+		password string
+		username string
+		want     []string
+	}{
+		{"Valid1!pass", "alice", []string{}},
+		{"Sh0rt!", "alice", []string{"must be 8-20 characters"}},
+		{"alllower1!", "alice", []string{"must contain an upper case letter"}},
+		{"ALLUPPER1!", "alice", []string{"must contain a lower case letter"}},
+		{"NoDigits!!", "alice", []string{"must contain a number"}},
+		{"NoSymbol11", "alice", []string{"must contain a symbol"}},
+		{"Élan1!élan", "alice", []string{}}, // Letters outside ASCII count towards the classes
+		{"alice1!ALICE", "ALICE1!alice", []string{"must not be the same as the username"}},
+		{"PASSWORD1!", "alice", []string{"must contain a lower case letter", "is too common, please choose another"}},
+		{
+			"abc", "abc",
+			[]string{
+				"must be 8-20 characters", "must contain an upper case letter", "must contain a number",
+				"must contain a symbol", "must not be the same as the username",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		if got := crm.ValidatePassword(test.password, test.username); !slices.Equal(got, test.want) {
+			t.Errorf("ValidatePassword(%q, %q) = %q, want %q", test.password, test.username, got, test.want)
+		}
+	}
+}
+
+func TestValidateUsername(t *testing.T) {
+	deletedAt := time.Now()
+	usersFilePath := filepath.Join(t.TempDir(), "users.json")
+
+	err := filehandler.WriteFile(usersFilePath, UsersList{
+		Version: filehandler.LatestVersion(Migrations),
+		Users:   []User{{ID: "1", Username: "alice"}, {ID: "2", Username: "bob", DeletedAt: &deletedAt}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	crm := &CRMHandler{
+		config: &configuration.Config{
+			Users:          configuration.UsersConfig{FilePath: usersFilePath},
+			UsernamePolicy: configuration.UsernamePolicy{MinLength: 3, MaxLength: 12, AllowedDescription: "is invalid"},
+		},
+		usernamePattern: regexp.MustCompile(`^[a-z0-9_]+$`),
+	}
+
+	tests := []struct {
+		username string
+		want     []string
+	}{
+		{"carol", []string{}},
+		{"bob", []string{}}, // Usernames of deleted users can be reused
+		{"al", []string{"must be 3-12 characters"}},
+		{"carol.smith", []string{"is invalid"}},
+		{"alice", []string{"is already taken"}},
+		{"ALICE", []string{"is invalid", "is already taken"}},
+		{"", []string{"must be 3-12 characters", "is invalid"}},
+	}
+
+	for _, test := range tests {
+		if got := crm.ValidateUsername(test.username); !slices.Equal(got, test.want) {
+			t.Errorf("ValidateUsername(%q) = %q, want %q", test.username, got, test.want)
+		}
+	}
+
+	// Without a description, a generic one is shown for usernames not matching the pattern
+	crm.config.UsernamePolicy.AllowedDescription = ""
+
+	want := []string{defaultAllowedDescription}
+	if got := crm.ValidateUsername("carol.smith"); !slices.Equal(got, want) {
+		t.Errorf("ValidateUsername() without a description = %q, want %q", got, want)
+	}
+}
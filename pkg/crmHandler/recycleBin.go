@@ -0,0 +1,154 @@
+package crmhandler
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"time"
+	audithandler "work-mini-project/pkg/auditHandler"
+)
+
+var errUserNotDeleted = errors.New("specified user is not in the recycle bin")
+
+var errRetentionPeriod = errors.New("user cannot be purged until the retention period has passed")
+
+func (user User) IsDeleted() bool {
+	return user.DeletedAt != nil
+}
+
+// ActiveUsers returns the users that are not in the recycle bin or awaiting approval.
+func (crm *CRMHandler) ActiveUsers() []User {
+	return slices.DeleteFunc(slices.Clone(crm.Users), func(user User) bool {
+		return user.IsDeleted() || user.Pending
+	})
+}
+
+// DeletedUsers returns the users in the recycle bin.
+func (crm *CRMHandler) DeletedUsers() []User {
+	return slices.DeleteFunc(slices.Clone(crm.Users), func(user User) bool {
+		return !user.IsDeleted()
+	})
+}
+
+// PurgeableAt returns when a deleted user's retention period ends.
+func (crm *CRMHandler) PurgeableAt(user User) time.Time {
+	if !user.IsDeleted() {
+		return time.Time{}
+	}
+
+	return user.DeletedAt.AddDate(0, 0, crm.config.RecycleBin.RetentionDays)
+}
+
+func (crm *CRMHandler) canPurge(user User) bool {
+	return user.IsDeleted() && !time.Now().Before(crm.PurgeableAt(user))
+}
+
+// RestoreUser takes a user out of the recycle bin,
+// as long as its username hasn't since been reused.
+func (crm *CRMHandler) RestoreUser(id string, restoredBy string) error {
+	// Pick up any changes from other processes before modifying the store
+	err := crm.Reload()
+	if err != nil {
+		return err
+	}
+
+	index := crm.indexOf(id)
+	if index == -1 {
+		return wrapError(errUserNotFound)
+	}
+
+	if !crm.Users[index].IsDeleted() {
+		return wrapError(errUserNotDeleted)
+	}
+
+	restored := crm.Users[index]
+	restored.DeletedAt = nil
+	restored.DeletedBy = ""
+
+	usernameMatchIdx := slices.IndexFunc(crm.Users, func(E User) bool {
+		return strings.EqualFold(E.Username, restored.Username) && !E.IsDeleted()
+	})
+	if usernameMatchIdx != -1 {
+		return wrapError(errUserAlreadyExists)
+	}
+
+	before := crm.Users[index]
+	crm.Users[index] = restored
+
+	// Update persistent users store
+	err = crm.save()
+	if err != nil {
+		return err
+	}
+
+	return crm.audit(audithandler.ActionUserRestore, restoredBy, "", restored, &before, &restored)
+}
+
+// PurgeUser permanently removes a user from the recycle bin, once its retention period has passed.
+func (crm *CRMHandler) PurgeUser(id string, purgedBy string) error {
+	// Pick up any changes from other processes before modifying the store
+	err := crm.Reload()
+	if err != nil {
+		return err
+	}
+
+	index := crm.indexOf(id)
+	if index == -1 {
+		return wrapError(errUserNotFound)
+	}
+
+	if !crm.Users[index].IsDeleted() {
+		return wrapError(errUserNotDeleted)
+	}
+
+	if !crm.canPurge(crm.Users[index]) {
+		return wrapError(errRetentionPeriod)
+	}
+
+	purged := crm.Users[index]
+
+	// Crop the user out of the stored users list
+	crm.Users = append(crm.Users[:index], crm.Users[index+1:]...)
+
+	// Update persistent users store
+	err = crm.save()
+	if err != nil {
+		return err
+	}
+
+	return crm.audit(audithandler.ActionUserPurge, purgedBy, "", purged, &purged, nil)
+}
+
+// PurgeExpiredUsers permanently removes every user whose retention period has passed,
+// returning the number removed.
+func (crm *CRMHandler) PurgeExpiredUsers(purgedBy string) (int, error) {
+	// Pick up any changes from other processes before modifying the store
+	err := crm.Reload()
+	if err != nil {
+		return 0, err
+	}
+
+	purged := slices.DeleteFunc(slices.Clone(crm.Users), func(user User) bool {
+		return !crm.canPurge(user)
+	})
+	if len(purged) == 0 {
+		return 0, nil
+	}
+
+	crm.Users = slices.DeleteFunc(crm.Users, crm.canPurge)
+
+	// Update persistent users store
+	err = crm.save()
+	if err != nil {
+		return 0, err
+	}
+
+	for _, user := range purged {
+		err = crm.audit(audithandler.ActionUserPurge, purgedBy, "expired", user, &user, nil)
+		if err != nil {
+			return len(purged), err
+		}
+	}
+
+	return len(purged), nil
+}
@@ -0,0 +1,224 @@
+package crmhandler
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+	audithandler "work-mini-project/pkg/auditHandler"
+)
+
+// Registration modes, selected by registration.mode in config.
+const (
+	RegistrationOpen   = "open"   // Anyone can register, but must be approved before logging in
+	RegistrationInvite = "invite" // An invite code is required to register, and no approval is needed
+)
+
+// Days an invite code can be used for, if not configured.
+const defaultInviteExpiryDays = 7
+
+var errUnknownRegistrationMode = errors.New("unknown registration mode")
+
+var errAccountPending = errors.New("error, account is awaiting approval by an administrator")
+
+var errUserNotPending = errors.New("specified user is not awaiting approval")
+
+var errInvalidInvite = errors.New("error, invite code is not valid or has expired. please try again")
+
+var errInvitesDisabled = errors.New("invite codes are only used when registration is invite-only")
+
+const inviteCodePrompt = `
+Enter your invite code:`
+
+// An Invite allows one account to be registered when registration is invite-only.
+type Invite struct {
+	CodeHash  string    `json:"codeHash"`  // SHA-256 hash of the invite code
+	CreatedBy string    `json:"createdBy"` // ID of the user who created the invite
+	CreatedAt time.Time `json:"createdAt"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+func (invite Invite) IsExpired() bool {
+	return !time.Now().Before(invite.ExpiresAt)
+}
+
+// The configured registration mode. Configs from before registration was configurable have none, so are open.
+func (crm *CRMHandler) registrationMode() string {
+	if crm.config.Registration.Mode == "" {
+		return RegistrationOpen
+	}
+
+	return crm.config.Registration.Mode
+}
+
+// Check the configured registration mode is one we support.
+func (crm *CRMHandler) validateRegistrationMode() error {
+	switch crm.registrationMode() {
+	case RegistrationOpen, RegistrationInvite:
+		return nil
+	default:
+		return wrapError(fmt.Errorf("%w %q", errUnknownRegistrationMode, crm.config.Registration.Mode))
+	}
+}
+
+// InviteOnly reports whether an invite code is needed to register.
+func (crm *CRMHandler) InviteOnly() bool {
+	return crm.registrationMode() == RegistrationInvite
+}
+
+// PendingUsers returns the registered users awaiting approval.
+func (crm *CRMHandler) PendingUsers() []User {
+	return slices.DeleteFunc(slices.Clone(crm.Users), func(user User) bool {
+		return !user.Pending || user.IsDeleted()
+	})
+}
+
+// Find the index of the pending user with the given ID.
+func (crm *CRMHandler) pendingIndexOf(id string) (int, error) {
+	err := crm.Reload()
+	if err != nil {
+		return -1, err
+	}
+
+	index := crm.indexOf(id)
+	if index == -1 || crm.Users[index].IsDeleted() {
+		return -1, wrapError(errUserNotFound)
+	}
+
+	if !crm.Users[index].Pending {
+		return -1, wrapError(errUserNotPending)
+	}
+
+	return index, nil
+}
+
+// ApproveUser allows a pending user to log in.
+func (crm *CRMHandler) ApproveUser(id string, approvedBy string) error {
+	index, err := crm.pendingIndexOf(id)
+	if err != nil {
+		return err
+	}
+
+	before := crm.Users[index]
+	crm.Users[index].Pending = false
+
+	err = crm.save()
+	if err != nil {
+		return err
+	}
+
+	crm.securityLog.Printf("registration approved for %q, by user ID %s", before.Username, approvedBy)
+
+	return crm.audit(audithandler.ActionUserApprove, approvedBy, "", before, &before, &crm.Users[index])
+}
+
+// RejectUser permanently removes a pending user, freeing the username to be registered again.
+func (crm *CRMHandler) RejectUser(id string, rejectedBy string) error {
+	index, err := crm.pendingIndexOf(id)
+	if err != nil {
+		return err
+	}
+
+	rejected := crm.Users[index]
+	crm.Users = slices.Delete(crm.Users, index, index+1)
+
+	err = crm.save()
+	if err != nil {
+		return err
+	}
+
+	crm.securityLog.Printf("registration rejected for %q, by user ID %s", rejected.Username, rejectedBy)
+
+	return crm.audit(audithandler.ActionUserReject, rejectedBy, "", rejected, &rejected, nil)
+}
+
+// CreateInvite generates a single-use invite code, valid for the configured number of days.
+// Returns the code, to be given to the person being invited.
+func (crm *CRMHandler) CreateInvite(createdBy string) (string, time.Time, error) {
+	if !crm.InviteOnly() {
+		return "", time.Time{}, wrapError(errInvitesDisabled)
+	}
+
+	code, hash, err := generateCode()
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	err = crm.Reload()
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	expiryDays := crm.config.Registration.InviteExpiryDays
+	if expiryDays <= 0 {
+		expiryDays = defaultInviteExpiryDays
+	}
+
+	now := time.Now()
+	invite := Invite{CodeHash: hash, CreatedBy: createdBy, CreatedAt: now, ExpiresAt: now.AddDate(0, 0, expiryDays)}
+
+	// Expired invites can never be used, so are tidied away whenever a new one is created
+	crm.Invites = append(slices.DeleteFunc(crm.Invites, Invite.IsExpired), invite)
+
+	err = crm.save()
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	crm.securityLog.Printf("invite code created, by user ID %s", createdBy)
+
+	err = crm.auditLog.Record(audithandler.Entry{
+		ActorID: createdBy,
+		Action:  audithandler.ActionInviteCreate,
+		Detail:  "expires " + invite.ExpiresAt.Format(time.DateTime),
+	}, nil, nil)
+	if err != nil {
+		return "", time.Time{}, wrapError(err)
+	}
+
+	return code, invite.ExpiresAt, nil
+}
+
+// Prompt for an invite code, returning the hash of a valid, unexpired code.
+func (crm *CRMHandler) promptInvite() (string, error) {
+	code, err := crm.cliHandler.GetUserInput(inviteCodePrompt)
+	if err != nil {
+		return "", wrapError(err)
+	}
+
+	err = crm.Reload()
+	if err != nil {
+		return "", err
+	}
+
+	hash := hashCode(strings.TrimSpace(code))
+
+	valid := slices.ContainsFunc(crm.Invites, func(invite Invite) bool {
+		return invite.CodeHash == hash && !invite.IsExpired()
+	})
+	if !valid {
+		return "", errInvalidInvite
+	}
+
+	return hash, nil
+}
+
+// Use up an invite code, so it can't be used to register another account.
+func (crm *CRMHandler) consumeInvite(hash string) error {
+	err := crm.Reload()
+	if err != nil {
+		return err
+	}
+
+	index := slices.IndexFunc(crm.Invites, func(invite Invite) bool {
+		return invite.CodeHash == hash && !invite.IsExpired()
+	})
+	if index == -1 {
+		return errInvalidInvite
+	}
+
+	crm.Invites = slices.Delete(crm.Invites, index, index+1)
+
+	return crm.save()
+}
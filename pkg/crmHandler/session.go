@@ -0,0 +1,120 @@
+package crmhandler
+
+import (
+	"errors"
+	"time"
+	audithandler "work-mini-project/pkg/auditHandler"
+)
+
+var errSessionExpired = errors.New("error, session expired. please log in again")
+
+// A session is a logged in user's use of the app. It ends after a period of inactivity,
+// or once it reaches its maximum lifetime, after which the user must log in again.
+type session struct {
+	user         *User
+	startedAt    time.Time
+	lastActivity time.Time
+	timer        *time.Timer // Clears the screen when the session expires, so nothing is left on show
+}
+
+// LoggedInUser returns the user of the current session, or nil if no one is logged in.
+func (crm *CRMHandler) LoggedInUser() *User {
+	if crm.session == nil {
+		return nil
+	}
+
+	return crm.session.user
+}
+
+// When the session expires and why, or the zero time if it never does.
+func (crm *CRMHandler) sessionExpiry() (time.Time, string) {
+	idleTimeout := time.Duration(crm.config.Session.IdleTimeoutMinutes) * time.Minute
+	maxLifetime := time.Duration(crm.config.Session.MaxLifetimeMinutes) * time.Minute
+
+	var expiry time.Time
+
+	reason := ""
+
+	if idleTimeout > 0 {
+		expiry, reason = crm.session.lastActivity.Add(idleTimeout), "after inactivity"
+	}
+
+	if maxLifetime > 0 {
+		if lifetimeExpiry := crm.session.startedAt.Add(maxLifetime); expiry.IsZero() || lifetimeExpiry.Before(expiry) {
+			expiry, reason = lifetimeExpiry, "at its maximum lifetime"
+		}
+	}
+
+	return expiry, reason
+}
+
+// Schedule clearing the screen for when the session expires.
+func (crm *CRMHandler) scheduleExpiry() {
+	if crm.session.timer != nil {
+		crm.session.timer.Stop()
+	}
+
+	expiry, _ := crm.sessionExpiry()
+	if expiry.IsZero() {
+		return
+	}
+
+	crm.session.timer = time.AfterFunc(time.Until(expiry), func() {
+		crm.cliHandler.ClearTerminal()
+		crm.cliHandler.WriteOutput(errSessionExpired.Error() + "\n\nPress enter to continue...")
+	})
+}
+
+// Start a session for a user who has just logged in.
+func (crm *CRMHandler) startSession(user User) {
+	now := time.Now()
+	crm.session = &session{user: &user, startedAt: now, lastActivity: now}
+
+	crm.scheduleExpiry()
+}
+
+// Logout ends the current session, if there is one.
+func (crm *CRMHandler) Logout() error {
+	return crm.endSession("")
+}
+
+// End the current session, recording why in the audit log.
+func (crm *CRMHandler) endSession(reason string) error {
+	if crm.session == nil {
+		return nil
+	}
+
+	if crm.session.timer != nil {
+		crm.session.timer.Stop()
+	}
+
+	user := *crm.session.user
+	crm.session = nil
+
+	return crm.audit(audithandler.ActionLogout, user.ID, reason, user, nil, nil)
+}
+
+// CheckSession is run whenever the user enters input. It ends the session and returns an error if the session
+// has expired, otherwise it records the activity.
+func (crm *CRMHandler) CheckSession() error {
+	if crm.session == nil {
+		return nil
+	}
+
+	expiry, reason := crm.sessionExpiry()
+	if !expiry.IsZero() && !time.Now().Before(expiry) {
+		crm.securityLog.Printf("session for %q expired %s", crm.session.user.Username, reason)
+
+		err := crm.endSession("session expired " + reason)
+		if err != nil {
+			return err
+		}
+
+		return errSessionExpired
+	}
+
+	crm.session.lastActivity = time.Now()
+	crm.scheduleExpiry()
+
+	return nil
+}
@@ -0,0 +1,383 @@
+package crmhandler
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1" //nolint:gosec // RFC 6238 TOTP uses HMAC-SHA1, which authenticator apps expect by default
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+	audithandler "work-mini-project/pkg/auditHandler"
+	qrhandler "work-mini-project/pkg/qrHandler"
+)
+
+// TOTP parameters, matching the defaults of authenticator apps.
+const (
+	totpSecretLength = 20
+	totpDigits       = 6
+	totpPeriod       = 30 * time.Second
+	totpSkew         = 1 // Codes from this many periods either side of now are accepted, to allow for clock drift
+)
+
+// Recovery codes issued on enrolment, if not configured.
+const defaultRecoveryCodes = 10
+
+var errTwoFactorNotEnrolled = errors.New("two-factor authentication is not enabled for this user")
+
+var errTwoFactorAlreadyEnrolled = errors.New("two-factor authentication is already enabled for this user")
+
+var errTwoFactorRequired = errors.New("error, two-factor authentication is required for your role")
+
+var errIncorrectTwoFactorCode = errors.New("error, authentication code was not valid. please try again")
+
+const (
+	twoFactorCodePrompt = `
+Enter the code from your authenticator app, or a recovery code:`
+
+	enrolCodePrompt = `
+Enter the code from your authenticator app to confirm, or leave blank to cancel:`
+)
+
+// HasTwoFactor reports whether the user has enrolled in two-factor authentication.
+func (user User) HasTwoFactor() bool {
+	return user.TOTPSecret != ""
+}
+
+// RequiresTwoFactor reports whether the user's role must use two-factor authentication.
+func (crm *CRMHandler) RequiresTwoFactor(user User) bool {
+	return slices.Contains(crm.config.TwoFactor.RequiredRoles, user.Role)
+}
+
+// TOTP code for a time step, as defined by RFC 6238.
+func totpCode(secret []byte, step int64) string {
+	mac := hmac.New(sha1.New, secret)
+
+	//nolint:gosec // Time steps are never negative
+	_ = binary.Write(mac, binary.BigEndian, uint64(step))
+	sum := mac.Sum(nil)
+
+	// Dynamic truncation, from RFC 4226
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:]) & 0x7fffffff
+
+	modulus := uint32(1)
+	for range totpDigits {
+		modulus *= 10
+	}
+
+	return fmt.Sprintf("%0*d", totpDigits, value%modulus)
+}
+
+// Check a TOTP code against the secret, returning the time step it matched.
+// Steps at or before lastStep are rejected, so a code can only be used once.
+func verifyTOTP(secret string, code string, lastStep int64) (int64, bool) {
+	key, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(secret)
+	if err != nil || len(code) != totpDigits {
+		return 0, false
+	}
+
+	now := time.Now().Unix() / int64(totpPeriod.Seconds())
+
+	for step := now - totpSkew; step <= now+totpSkew; step++ {
+		if step <= lastStep {
+			continue
+		}
+
+		if subtle.ConstantTimeCompare([]byte(totpCode(key, step)), []byte(code)) == 1 {
+			return step, true
+		}
+	}
+
+	return 0, false
+}
+
+// Generate a set of recovery codes, returning the codes to show the user and the hashes to store.
+func (crm *CRMHandler) generateRecoveryCodes() ([]string, []string, error) {
+	count := crm.config.TwoFactor.RecoveryCodeCount
+	if count <= 0 {
+		count = defaultRecoveryCodes
+	}
+
+	codes := make([]string, count)
+	hashes := make([]string, count)
+
+	for i := range count {
+		code, hash, err := generateCode()
+		if err != nil {
+			return nil, nil, err
+		}
+
+		codes[i] = code
+		hashes[i] = hash
+	}
+
+	return codes, hashes, nil
+}
+
+// Key URI understood by authenticator apps, see https://github.com/google/google-authenticator/wiki/Key-Uri-Format.
+func (crm *CRMHandler) otpauthURI(username string, secret string) string {
+	issuer := crm.config.TwoFactor.Issuer
+
+	query := url.Values{
+		"secret":    {secret},
+		"issuer":    {issuer},
+		"algorithm": {"SHA1"},
+		"digits":    {fmt.Sprint(totpDigits)},
+		"period":    {fmt.Sprint(int(totpPeriod.Seconds()))},
+	}.Encode()
+
+	// Authenticator apps expect spaces encoded as %20 rather than +
+	return "otpauth://totp/" + url.PathEscape(issuer+":"+username) + "?" + strings.ReplaceAll(query, "+", "%20")
+}
+
+// Update the two-factor fields of a stored user.
+func (crm *CRMHandler) updateTwoFactor(id string, update func(user *User)) error {
+	err := crm.Reload()
+	if err != nil {
+		return err
+	}
+
+	index := crm.indexOf(id)
+	if index == -1 || crm.Users[index].IsDeleted() {
+		return wrapError(errUserNotFound)
+	}
+
+	update(&crm.Users[index])
+
+	return crm.save()
+}
+
+// Show the recovery codes, which are only ever shown once.
+func (crm *CRMHandler) showRecoveryCodes(codes []string) {
+	crm.cliHandler.WriteOutput(
+		"\nRecovery codes, each of which can be used once in place of an authentication code." +
+			"\nStore them somewhere safe, they will not be shown again:\n\n" + strings.Join(codes, "\n"),
+	)
+}
+
+// EnrolTwoFactor sets up TOTP two-factor authentication for a user. The secret is shown as a QR code
+// and otpauth URI to add to an authenticator app, and only saved once a code from the app is confirmed.
+func (crm *CRMHandler) EnrolTwoFactor(id string) error {
+	user, err := crm.GetUserByID(id)
+	if err != nil {
+		return err
+	}
+
+	if user.HasTwoFactor() {
+		return wrapError(errTwoFactorAlreadyEnrolled)
+	}
+
+	key := make([]byte, totpSecretLength)
+
+	_, err = rand.Read(key)
+	if err != nil {
+		return wrapError(err)
+	}
+
+	secret := base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(key)
+	uri := crm.otpauthURI(user.Username, secret)
+
+	crm.cliHandler.WriteOutput("\nScan this QR code with your authenticator app:\n")
+
+	code, err := qrhandler.Encode(uri)
+	if err == nil {
+		crm.cliHandler.WriteOutput(code.RenderText())
+	}
+
+	crm.cliHandler.WriteOutput("Or add this key URI:\n" + uri + "\n\nOr enter this key manually: " + secret)
+
+	for {
+		input, err := crm.cliHandler.GetUserInput(enrolCodePrompt)
+		if err != nil {
+			return wrapError(err)
+		}
+
+		input = strings.TrimSpace(input)
+		if input == "" {
+			return wrapError(errTwoFactorNotEnrolled)
+		}
+
+		step, valid := verifyTOTP(secret, input, 0)
+		if !valid {
+			crm.cliHandler.WriteOutput(errIncorrectTwoFactorCode.Error())
+
+			continue
+		}
+
+		codes, hashes, err := crm.generateRecoveryCodes()
+		if err != nil {
+			return err
+		}
+
+		err = crm.updateTwoFactor(id, func(user *User) {
+			user.TOTPSecret = secret
+			user.TOTPLastStep = step
+			user.RecoveryCodes = hashes
+		})
+		if err != nil {
+			return err
+		}
+
+		crm.securityLog.Printf("two-factor authentication enabled for %q", user.Username)
+		crm.cliHandler.WriteOutput("Two-factor authentication enabled")
+		crm.showRecoveryCodes(codes)
+
+		return crm.audit(audithandler.ActionTwoFactorEnable, user.ID, "", user, nil, nil)
+	}
+}
+
+// Prompt for an authentication code or recovery code, consuming it if valid.
+func (crm *CRMHandler) verifyTwoFactor(user User) error {
+	input, err := crm.cliHandler.GetSensitiveInput(twoFactorCodePrompt)
+	if err != nil {
+		return wrapError(err)
+	}
+
+	remaining, err := crm.consumeTwoFactorCode(user.ID, strings.TrimSpace(input))
+	if err != nil {
+		return err
+	}
+
+	if remaining >= 0 {
+		crm.securityLog.Printf("recovery code used by %q, %d remaining", user.Username, remaining)
+		crm.cliHandler.WriteOutput(fmt.Sprintf("Recovery code accepted, %d remaining", remaining))
+	}
+
+	return nil
+}
+
+// Check an authentication code or recovery code against the stored user, as another instance may have used
+// a code since the user was read, and save that it has been used. Returns the number of recovery codes left
+// if a recovery code was used, or -1 for an authentication code.
+func (crm *CRMHandler) consumeTwoFactorCode(id string, code string) (int, error) {
+	err := crm.Reload()
+	if err != nil {
+		return 0, err
+	}
+
+	index := crm.indexOf(id)
+	if index == -1 || crm.Users[index].IsDeleted() {
+		return 0, wrapError(errUserNotFound)
+	}
+
+	user := &crm.Users[index]
+	remaining := -1
+
+	step, valid := verifyTOTP(user.TOTPSecret, code, user.TOTPLastStep)
+	if valid {
+		user.TOTPLastStep = step
+	} else {
+		codeIndex := slices.Index(user.RecoveryCodes, hashCode(code))
+		if codeIndex == -1 {
+			return 0, errIncorrectTwoFactorCode
+		}
+
+		user.RecoveryCodes = slices.Delete(user.RecoveryCodes, codeIndex, codeIndex+1)
+		remaining = len(user.RecoveryCodes)
+	}
+
+	err = crm.save()
+	if err != nil {
+		return 0, err
+	}
+
+	return remaining, nil
+}
+
+// DisableTwoFactor turns off two-factor authentication for the logged in user, after confirming a current code.
+// Users whose role requires two-factor authentication can't disable it.
+func (crm *CRMHandler) DisableTwoFactor() error {
+	user, err := crm.GetUserByID(crm.LoggedInUser().ID)
+	if err != nil {
+		return err
+	}
+
+	if !user.HasTwoFactor() {
+		return wrapError(errTwoFactorNotEnrolled)
+	}
+
+	if crm.RequiresTwoFactor(user) {
+		return errTwoFactorRequired
+	}
+
+	err = crm.verifyTwoFactor(user)
+	if err != nil {
+		return err
+	}
+
+	err = crm.updateTwoFactor(user.ID, clearTwoFactor)
+	if err != nil {
+		return err
+	}
+
+	crm.securityLog.Printf("two-factor authentication disabled for %q", user.Username)
+	crm.cliHandler.WriteOutput("Two-factor authentication disabled")
+
+	return crm.audit(audithandler.ActionTwoFactorDisable, user.ID, "", user, nil, nil)
+}
+
+// RegenerateRecoveryCodes replaces the logged in user's recovery codes, after confirming a current code.
+func (crm *CRMHandler) RegenerateRecoveryCodes() error {
+	user, err := crm.GetUserByID(crm.LoggedInUser().ID)
+	if err != nil {
+		return err
+	}
+
+	if !user.HasTwoFactor() {
+		return wrapError(errTwoFactorNotEnrolled)
+	}
+
+	err = crm.verifyTwoFactor(user)
+	if err != nil {
+		return err
+	}
+
+	codes, hashes, err := crm.generateRecoveryCodes()
+	if err != nil {
+		return err
+	}
+
+	err = crm.updateTwoFactor(user.ID, func(user *User) { user.RecoveryCodes = hashes })
+	if err != nil {
+		return err
+	}
+
+	crm.securityLog.Printf("recovery codes regenerated for %q", user.Username)
+	crm.showRecoveryCodes(codes)
+
+	return nil
+}
+
+// ResetTwoFactor removes a user's two-factor authentication, for when they have lost their authenticator app
+// and recovery codes. Users whose role requires it must enrol again at next login.
+func (crm *CRMHandler) ResetTwoFactor(id string, resetBy string) error {
+	user, err := crm.GetUserByID(id)
+	if err != nil {
+		return err
+	}
+
+	if !user.HasTwoFactor() {
+		return wrapError(errTwoFactorNotEnrolled)
+	}
+
+	err = crm.updateTwoFactor(id, clearTwoFactor)
+	if err != nil {
+		return err
+	}
+
+	crm.securityLog.Printf("two-factor authentication reset for %q, by user ID %s", user.Username, resetBy)
+
+	return crm.audit(audithandler.ActionTwoFactorReset, resetBy, "", user, nil, nil)
+}
+
+func clearTwoFactor(user *User) {
+	user.TOTPSecret = ""
+	user.TOTPLastStep = 0
+	user.RecoveryCodes = nil
+}
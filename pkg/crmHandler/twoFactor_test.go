@@ -0,0 +1,55 @@
+package crmhandler
+
+import (
+	"encoding/base32"
+	"testing"
+	"time"
+)
+
+// Shared secret of the RFC 6238 test vectors, for SHA-1.
+var rfc6238Secret = []byte("12345678901234567890")
+
+func TestTOTPCode(t *testing.T) {
+	// RFC 6238 Appendix B gives 8 digit codes, of which 6 digit codes are the last 6 digits
+	tests := []struct {
+		unixTime int64
+		want     string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+
+	for _, test := range tests {
+		step := test.unixTime / int64(totpPeriod.Seconds())
+		if got := totpCode(rfc6238Secret, step); got != test.want {
+			t.Errorf("totpCode() at %d = %s, want %s", test.unixTime, got, test.want)
+		}
+	}
+}
+
+func TestVerifyTOTP(t *testing.T) {
+	secret := base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(rfc6238Secret)
+	now := time.Now().Unix() / int64(totpPeriod.Seconds())
+
+	step, valid := verifyTOTP(secret, totpCode(rfc6238Secret, now), 0)
+	if !valid || step != now {
+		t.Fatalf("verifyTOTP() = %d, %t, want %d, true", step, valid, now)
+	}
+
+	// Codes can't be replayed once their step has been used
+	if _, valid = verifyTOTP(secret, totpCode(rfc6238Secret, now), now); valid {
+		t.Error("verifyTOTP() accepted a code for an already used step")
+	}
+
+	if _, valid = verifyTOTP(secret, totpCode(rfc6238Secret, now-totpSkew-1), 0); valid {
+		t.Error("verifyTOTP() accepted a code outside the allowed clock skew")
+	}
+
+	if _, valid = verifyTOTP(secret, "12345", 0); valid {
+		t.Error("verifyTOTP() accepted a code of the wrong length")
+	}
+}
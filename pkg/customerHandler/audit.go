@@ -0,0 +1,78 @@
+package customerhandler
+
+import (
+	"slices"
+	"time"
+	audithandler "work-mini-project/pkg/auditHandler"
+)
+
+// Fields of a customer recorded in the audit log. The address, contacts, notes, delivery instructions, location
+// and pricing agreement are personal or commercial data, left out as the log is never encrypted or purged,
+// so only the names of those fields are recorded when they change.
+type auditedCustomer struct {
+	Name          string     `json:"name"`
+	AccountNumber string     `json:"accountNumber,omitempty"`
+	Region        string     `json:"region,omitempty"`
+	Tags          []string   `json:"tags,omitempty"`
+	DeletedAt     *time.Time `json:"deletedAt,omitempty"`
+	DeletedBy     string     `json:"deletedBy,omitempty"`
+}
+
+func auditView(customer *Customer) any {
+	// Return an untyped nil, so missing values are left out of the entry
+	if customer == nil {
+		return nil
+	}
+
+	return auditedCustomer{
+		Name:          customer.Name,
+		AccountNumber: customer.AccountNumber,
+		Region:        customer.Region,
+		Tags:          customer.Tags,
+		DeletedAt:     customer.DeletedAt,
+		DeletedBy:     customer.DeletedBy,
+	}
+}
+
+// Record a change to a customer in the audit log, with the customer's audited fields before and after the change,
+// and the names of any other fields changed. Before is nil for new customers, and after is nil for purged ones.
+func (ch *CustomerHandler) audit(
+	action string, actorID string, detail string, before *Customer, after *Customer,
+) error {
+	target := after
+	if target == nil {
+		target = before
+	}
+
+	var leftOut []string
+
+	if before != nil && after != nil {
+		changed, err := audithandler.ChangedFields(before, after)
+		if err != nil {
+			return wrapError(err)
+		}
+
+		audited, err := audithandler.ChangedFields(auditView(before), auditView(after))
+		if err != nil {
+			return wrapError(err)
+		}
+
+		leftOut = slices.DeleteFunc(changed, func(field string) bool {
+			return slices.Contains(audited, field)
+		})
+	}
+
+	err := ch.auditLog.Record(audithandler.Entry{
+		ActorID:    actorID,
+		Action:     action,
+		TargetID:   target.ID,
+		TargetName: target.Name,
+		Detail:     detail,
+		Fields:     leftOut,
+	}, auditView(before), auditView(after))
+	if err != nil {
+		return wrapError(err)
+	}
+
+	return nil
+}
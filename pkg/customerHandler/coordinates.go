@@ -0,0 +1,33 @@
+package customerhandler
+
+import geohandler "work-mini-project/pkg/geoHandler"
+
+// LookupPostcode returns the location of a postcode from the configured postcode file, or nil if it isn't listed.
+func (ch *CustomerHandler) LookupPostcode(postcode string) *geohandler.Coordinates {
+	coordinates, ok := ch.postcodes[geohandler.NormalisePostcode(postcode)]
+	if !ok {
+		return nil
+	}
+
+	return &coordinates
+}
+
+// Check a customer's coordinates, if it has any, and project them to set its grid location.
+// Without a configured projection the grid location is left as entered.
+func (ch *CustomerHandler) applyCoordinates(customer *Customer) error {
+	if customer.Coordinates == nil {
+		return nil
+	}
+
+	err := customer.Coordinates.Validate()
+	if err != nil {
+		return wrapError(err)
+	}
+
+	projection := ch.config.GridProjection()
+	if projection.Enabled() {
+		customer.GridX, customer.GridY = projection.ToGrid(*customer.Coordinates)
+	}
+
+	return nil
+}
@@ -0,0 +1,364 @@
+package customerhandler
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	audithandler "work-mini-project/pkg/auditHandler"
+	filehandler "work-mini-project/pkg/fileHandler"
+	geohandler "work-mini-project/pkg/geoHandler"
+	idhandler "work-mini-project/pkg/idHandler"
+)
+
+// Columns used when exporting customers to CSV. Imports match columns by header, so may omit optional columns.
+var csvColumns = []string{
+	"name", "gridX", "gridY", "accountNumber",
+	"addressLine1", "addressLine2", "town", "county", "postcode",
+	"contacts", "notes", "deliveryInstructions", "tags", "region", "latitude", "longitude",
+}
+
+// Contacts are stored in a single CSV column as "name|phone|email" entries separated by semicolons.
+// Tags are stored in a single column separated by semicolons.
+const (
+	csvContactSeparator      = ";"
+	csvContactFieldSeparator = "|"
+	csvTagSeparator          = ";"
+)
+
+// ImportRowError describes why a row of an import could not be accepted.
+type ImportRowError struct {
+	Row int
+	Err error
+}
+
+// ImportReport is the outcome of importing customers.
+// Customers are only added if there are no errors, and the import wasn't a dry run.
+type ImportReport struct {
+	Customers []Customer
+	Errors    []ImportRowError
+	Committed bool
+}
+
+var errMissingColumn = errors.New("csv is missing required column")
+
+var errInvalidContactFormat = errors.New(`contacts must be formatted as "name|phone|email", separated by ";"`)
+
+var errUnsupportedExportFormat = errors.New("export file must have a .csv or .json extension")
+
+// ImportCSV reads customers from CSV, validating every row against the grid limits and for unique names
+// and account numbers. Customers are only added if every row is valid and dryRun is false.
+func (ch *CustomerHandler) ImportCSV(reader io.Reader, dryRun bool, importedBy string) (*ImportReport, error) {
+	// Pick up any changes from other processes before validating against the store
+	err := ch.Reload()
+	if err != nil {
+		return nil, err
+	}
+
+	csvReader := csv.NewReader(reader)
+
+	// Allow rows with missing trailing fields, so they are reported per row rather than failing the whole file
+	csvReader.FieldsPerRecord = -1
+
+	records, err := csvReader.ReadAll()
+	if err != nil {
+		return nil, wrapError(err)
+	}
+
+	report := &ImportReport{Customers: []Customer{}, Errors: []ImportRowError{}}
+
+	if len(records) == 0 {
+		return report, nil
+	}
+
+	columns := map[string]int{}
+	for i, header := range records[0] {
+		columns[strings.TrimSpace(header)] = i
+	}
+
+	for _, required := range []string{"name", "gridX", "gridY"} {
+		if _, ok := columns[required]; !ok {
+			return nil, wrapError(fmt.Errorf("%w: %s", errMissingColumn, required))
+		}
+	}
+
+	names := map[string]bool{}
+	accountNumbers := map[string]bool{}
+
+	for i, record := range records[1:] {
+		// Row numbers match the line in the file, after the header
+		row := i + 2
+
+		customer, err := parseCSVRecord(record, columns)
+		if err == nil {
+			customer.Region = ch.normaliseRegion(customer.Region)
+			err = ch.applyCoordinates(&customer)
+		}
+
+		if err == nil {
+			err = ch.validateCustomer(customer)
+		}
+
+		// Check for duplicates within the file itself
+		if err == nil && names[customer.Name] {
+			err = wrapError(errCustomerAlreadyExists)
+		}
+
+		if err == nil && customer.AccountNumber != "" && accountNumbers[customer.AccountNumber] {
+			err = wrapError(errAccountNumberInUse)
+		}
+
+		if err != nil {
+			report.Errors = append(report.Errors, ImportRowError{Row: row, Err: err})
+
+			continue
+		}
+
+		names[customer.Name] = true
+		accountNumbers[customer.AccountNumber] = true
+
+		report.Customers = append(report.Customers, customer)
+	}
+
+	if dryRun || len(report.Errors) > 0 {
+		return report, nil
+	}
+
+	return report, ch.commitImport(report, importedBy)
+}
+
+// Add every imported customer to the store in a single write, so an import is never partially applied.
+func (ch *CustomerHandler) commitImport(report *ImportReport, importedBy string) error {
+	imported := make([]Customer, len(report.Customers))
+
+	for i, customer := range report.Customers {
+		id, err := idhandler.Generate()
+		if err != nil {
+			return wrapError(err)
+		}
+
+		customer.ID = id
+		imported[i] = customer
+	}
+
+	previousCustomers := ch.Customers
+	ch.Customers = append(slices.Clone(ch.Customers), imported...)
+
+	err := ch.save()
+	if err != nil {
+		ch.Customers = previousCustomers
+
+		return err
+	}
+
+	report.Customers = imported
+	report.Committed = true
+
+	for _, customer := range imported {
+		err = ch.audit(audithandler.ActionCustomerAdd, importedBy, "import", nil, &customer)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func parseCSVRecord(record []string, columns map[string]int) (Customer, error) {
+	field := func(name string) string {
+		index, ok := columns[name]
+		if !ok || index >= len(record) {
+			return ""
+		}
+
+		return strings.TrimSpace(record[index])
+	}
+
+	gridX, err := strconv.Atoi(field("gridX"))
+	if err != nil {
+		return Customer{}, wrapError(fmt.Errorf("invalid gridX: %w", err))
+	}
+
+	gridY, err := strconv.Atoi(field("gridY"))
+	if err != nil {
+		return Customer{}, wrapError(fmt.Errorf("invalid gridY: %w", err))
+	}
+
+	contacts, err := parseCSVContacts(field("contacts"))
+	if err != nil {
+		return Customer{}, err
+	}
+
+	var coordinates *geohandler.Coordinates
+
+	if field("latitude") != "" || field("longitude") != "" {
+		parsed, err := geohandler.ParseCoordinates(field("latitude") + "," + field("longitude"))
+		if err != nil {
+			return Customer{}, wrapError(err)
+		}
+
+		coordinates = &parsed
+	}
+
+	return Customer{
+		Name:          field("name"),
+		GridX:         gridX,
+		GridY:         gridY,
+		Coordinates:   coordinates,
+		AccountNumber: field("accountNumber"),
+		Address: Address{
+			Line1:    field("addressLine1"),
+			Line2:    field("addressLine2"),
+			Town:     field("town"),
+			County:   field("county"),
+			Postcode: field("postcode"),
+		},
+		Contacts:             contacts,
+		Notes:                field("notes"),
+		DeliveryInstructions: field("deliveryInstructions"),
+		Tags:                 NormaliseTags(strings.Split(field("tags"), csvTagSeparator)),
+		Region:               field("region"),
+	}, nil
+}
+
+func parseCSVContacts(value string) ([]Contact, error) {
+	contacts := []Contact{}
+
+	for _, entry := range strings.Split(value, csvContactSeparator) {
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+
+		fields := strings.Split(entry, csvContactFieldSeparator)
+		if len(fields) > 3 {
+			return nil, wrapError(errInvalidContactFormat)
+		}
+
+		// Pad missing phone and email fields
+		fields = append(fields, "", "")
+
+		contacts = append(contacts, Contact{
+			Name:  strings.TrimSpace(fields[0]),
+			Phone: strings.TrimSpace(fields[1]),
+			Email: strings.TrimSpace(fields[2]),
+		})
+	}
+
+	return contacts, nil
+}
+
+func formatCSVContacts(contacts []Contact) string {
+	entries := make([]string, len(contacts))
+	for i, contact := range contacts {
+		entries[i] = strings.Join([]string{contact.Name, contact.Phone, contact.Email}, csvContactFieldSeparator)
+	}
+
+	return strings.Join(entries, csvContactSeparator)
+}
+
+// WriteCSV writes the customers as CSV, in the format accepted by ImportCSV.
+func WriteCSV(writer io.Writer, customers []Customer) error {
+	csvWriter := csv.NewWriter(writer)
+
+	err := csvWriter.Write(csvColumns)
+	if err != nil {
+		return wrapError(err)
+	}
+
+	for _, customer := range customers {
+		latitude, longitude := "", ""
+		if customer.Coordinates != nil {
+			latitude = strconv.FormatFloat(customer.Coordinates.Latitude, 'f', -1, 64)
+			longitude = strconv.FormatFloat(customer.Coordinates.Longitude, 'f', -1, 64)
+		}
+
+		err = csvWriter.Write([]string{
+			customer.Name,
+			strconv.Itoa(customer.GridX),
+			strconv.Itoa(customer.GridY),
+			customer.AccountNumber,
+			customer.Address.Line1,
+			customer.Address.Line2,
+			customer.Address.Town,
+			customer.Address.County,
+			customer.Address.Postcode,
+			formatCSVContacts(customer.Contacts),
+			customer.Notes,
+			customer.DeliveryInstructions,
+			strings.Join(customer.Tags, csvTagSeparator),
+			customer.Region,
+			latitude,
+			longitude,
+		})
+		if err != nil {
+			return wrapError(err)
+		}
+	}
+
+	csvWriter.Flush()
+
+	if err = csvWriter.Error(); err != nil {
+		return wrapError(err)
+	}
+
+	return nil
+}
+
+// ExportCustomers writes all customers not in the recycle bin to a CSV or JSON file, chosen by the file extension,
+// returning the number exported. Exports are always written as plaintext, even when the customer store is encrypted.
+// Pricing agreements are only included in JSON exports, and left out unless includePricing is set.
+func (ch *CustomerHandler) ExportCustomers(filePath string, includePricing bool) (int, error) {
+	err := ch.Reload()
+	if err != nil {
+		return 0, err
+	}
+
+	customers := ch.ActiveCustomers()
+
+	if !includePricing {
+		for i := range customers {
+			customers[i].Pricing = nil
+		}
+	}
+
+	switch strings.ToLower(filepath.Ext(filePath)) {
+	case ".csv":
+		file, err := os.Create(filePath)
+		if err != nil {
+			return 0, wrapError(err)
+		}
+
+		err = WriteCSV(file, customers)
+		if err != nil {
+			_ = file.Close()
+
+			return 0, err
+		}
+
+		err = file.Close()
+		if err != nil {
+			return 0, wrapError(err)
+		}
+
+		return len(customers), nil
+
+	case ".json":
+		err = filehandler.WriteFile(filePath, CustomerList{
+			Version:   filehandler.LatestVersion(Migrations),
+			Customers: customers,
+		})
+		if err != nil {
+			return 0, wrapError(err)
+		}
+
+		return len(customers), nil
+
+	default:
+		return 0, wrapError(errUnsupportedExportFormat)
+	}
+}
@@ -0,0 +1,199 @@
+package customerhandler
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	filehandler "work-mini-project/pkg/fileHandler"
+)
+
+func TestImportCSV(t *testing.T) {
+	existing := Customer{ID: "existing", Name: "Acme", AccountNumber: "ACC1", GridX: 5, GridY: 5}
+
+	const header = "name,gridX,gridY,accountNumber,contacts\n"
+
+	tests := []struct {
+		name          string
+		csv           string
+		dryRun        bool
+		wantErrors    map[int]error // Expected error for each rejected row, by line number
+		wantCommitted bool
+		wantStored    int
+	}{
+		{
+			name:          "every row valid",
+			csv:           header + "Beta,1,2,,\nGamma,3,4,ACC2,Jo|0123 456789|jo@example.com\n",
+			wantCommitted: true,
+			wantStored:    3,
+		},
+		{
+			name:       "dry run",
+			csv:        header + "Beta,1,2,,\nGamma,3,4,ACC2,\n",
+			dryRun:     true,
+			wantStored: 1,
+		},
+		{
+			name:       "one invalid row adds nothing",
+			csv:        header + "Beta,1,2,,\nGamma,x,4,,\nDelta,3,3,,\n",
+			wantErrors: map[int]error{3: strconv.ErrSyntax},
+			wantStored: 1,
+		},
+		{
+			name: "every invalid row is reported",
+			csv: header +
+				"Beta,1,2,,\n" +
+				"Beta,3,3,,\n" + // Duplicate within the file
+				"Acme,3,3,,\n" + // Duplicate of a stored customer
+				"Delta,99,1,,\n" +
+				"Echo,1,1,ACC1,\n" +
+				"Foxtrot,1,1,,|0123|\n",
+			wantErrors: map[int]error{
+				3: errCustomerAlreadyExists,
+				4: errCustomerAlreadyExists,
+				5: errOutsideGridLimits,
+				6: errAccountNumberInUse,
+				7: errInvalidContact,
+			},
+			wantStored: 1,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ch := newTestHandler(t, existing)
+
+			report, err := ch.ImportCSV(strings.NewReader(test.csv), test.dryRun, "importer")
+			if err != nil {
+				t.Fatalf("ImportCSV() error = %v", err)
+			}
+
+			if len(report.Errors) != len(test.wantErrors) {
+				t.Errorf("ImportCSV() reported %d errors, want %d: %v", len(report.Errors), len(test.wantErrors),
+					report.Errors)
+			}
+
+			for _, rowError := range report.Errors {
+				if want := test.wantErrors[rowError.Row]; !errors.Is(rowError.Err, want) {
+					t.Errorf("row %d error = %v, want %v", rowError.Row, rowError.Err, want)
+				}
+			}
+
+			if report.Committed != test.wantCommitted {
+				t.Errorf("Committed = %t, want %t", report.Committed, test.wantCommitted)
+			}
+
+			stored := storedCustomers(t, ch)
+			if len(stored) != test.wantStored {
+				t.Fatalf("store holds %d customers, want %d", len(stored), test.wantStored)
+			}
+
+			for _, customer := range stored {
+				if customer.ID == "" {
+					t.Errorf("imported customer %q has no ID", customer.Name)
+				}
+			}
+		})
+	}
+}
+
+func TestImportCSVMissingColumn(t *testing.T) {
+	ch := newTestHandler(t)
+
+	_, err := ch.ImportCSV(strings.NewReader("name,gridX\nBeta,1\n"), false, "importer")
+	if !errors.Is(err, errMissingColumn) {
+		t.Errorf("ImportCSV() error = %v, want %v", err, errMissingColumn)
+	}
+}
+
+func TestExportCustomers(t *testing.T) {
+	customers := []Customer{
+		{
+			ID: "a", Name: "Acme", GridX: 5, GridY: 5, Tags: []string{"priority", "fragile"},
+			Contacts: []Contact{{Name: "Jo", Phone: "0123", Email: "jo@example.com"}},
+			Pricing:  &PricingAgreement{DiscountPercent: 10},
+		},
+		{ID: "b", Name: "Beta", GridX: 1, GridY: 2, Address: Address{Town: "Leeds", Postcode: "LS1 1AA"}},
+	}
+
+	tests := []struct {
+		name           string
+		includePricing bool
+	}{
+		{"with pricing", true},
+		{"without pricing", false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ch := newTestHandler(t, customers...)
+			filePath := filepath.Join(t.TempDir(), "export.json")
+
+			exported, err := ch.ExportCustomers(filePath, test.includePricing)
+			if err != nil || exported != len(customers) {
+				t.Fatalf("ExportCustomers() = %d, %v, want %d", exported, err, len(customers))
+			}
+
+			list, err := filehandler.ReadFile[CustomerList](filePath)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if hasPricing := list.Customers[0].Pricing != nil; hasPricing != test.includePricing {
+				t.Errorf("export includes pricing = %t, want %t", hasPricing, test.includePricing)
+			}
+
+			// Leaving pricing out of the export must not change the store
+			if storedCustomers(t, ch)[0].Pricing == nil {
+				t.Error("store lost its pricing agreement")
+			}
+		})
+	}
+}
+
+func TestExportCSVRoundTrip(t *testing.T) {
+	customers := []Customer{
+		{
+			ID: "a", Name: "Acme", GridX: 5, GridY: 5, AccountNumber: "ACC1", Tags: []string{"priority", "fragile"},
+			Contacts: []Contact{{Name: "Jo", Phone: "0123", Email: "jo@example.com"}, {Name: "Sam"}},
+			Notes:    "Ring, then wait", Region: "North",
+		},
+		{ID: "b", Name: "Beta", GridX: 1, GridY: 2, Address: Address{Line1: "1 High St", Town: "Leeds"}},
+	}
+
+	filePath := filepath.Join(t.TempDir(), "export.csv")
+
+	_, err := newTestHandler(t, customers...).ExportCustomers(filePath, false)
+	if err != nil {
+		t.Fatalf("ExportCustomers() error = %v", err)
+	}
+
+	file, err := os.Open(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer file.Close()
+
+	report, err := newTestHandler(t).ImportCSV(file, false, "importer")
+	if err != nil || len(report.Errors) > 0 {
+		t.Fatalf("ImportCSV() = %v, %v", report, err)
+	}
+
+	for i, imported := range report.Customers {
+		// IDs are newly assigned on import
+		imported.ID = customers[i].ID
+		if !customersEqual(imported, customers[i]) {
+			t.Errorf("imported %+v, want %+v", imported, customers[i])
+		}
+	}
+}
+
+func customersEqual(a Customer, b Customer) bool {
+	return a.ID == b.ID && a.Name == b.Name && a.GridX == b.GridX && a.GridY == b.GridY &&
+		a.AccountNumber == b.AccountNumber && a.Address == b.Address && slices.Equal(a.Contacts, b.Contacts) &&
+		a.Notes == b.Notes && slices.Equal(a.Tags, b.Tags) && a.Region == b.Region
+}
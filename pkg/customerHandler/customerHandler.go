@@ -3,24 +3,91 @@ package customerhandler
 import (
 	"errors"
 	"fmt"
+	"net/mail"
 	"slices"
+	"time"
+	audithandler "work-mini-project/pkg/auditHandler"
 	"work-mini-project/pkg/configuration"
 	filehandler "work-mini-project/pkg/fileHandler"
+	geohandler "work-mini-project/pkg/geoHandler"
+	idhandler "work-mini-project/pkg/idHandler"
 )
 
-type Customer struct {
+type Address struct {
+	Line1    string `json:"line1,omitempty"`
+	Line2    string `json:"line2,omitempty"`
+	Town     string `json:"town,omitempty"`
+	County   string `json:"county,omitempty"`
+	Postcode string `json:"postcode,omitempty"`
+}
+
+type Contact struct {
 	Name  string `json:"name"`
-	GridX int    `json:"gridX"`
-	GridY int    `json:"gridY"`
+	Phone string `json:"phone,omitempty"`
+	Email string `json:"email,omitempty"`
+}
+
+type Customer struct {
+	ID                   string                  `json:"id"`
+	Name                 string                  `json:"name"`
+	GridX                int                     `json:"gridX"`
+	GridY                int                     `json:"gridY"`
+	Coordinates          *geohandler.Coordinates `json:"coordinates,omitempty"` // Sets the grid location when projected
+	AccountNumber        string                  `json:"accountNumber,omitempty"`
+	Address              Address                 `json:"address"`
+	Contacts             []Contact               `json:"contacts,omitempty"`
+	Notes                string                  `json:"notes,omitempty"`
+	DeliveryInstructions string                  `json:"deliveryInstructions,omitempty"`
+	Tags                 []string                `json:"tags,omitempty"`
+	Region               string                  `json:"region,omitempty"` // Overrides the region of the grid location
+	Pricing              *PricingAgreement       `json:"pricing,omitempty"`
+	DeletedAt            *time.Time              `json:"deletedAt,omitempty"`
+	DeletedBy            string                  `json:"deletedBy,omitempty"` // ID of the user who deleted the customer
+}
+
+// Lines returns the populated lines of the address, in postal order.
+func (address Address) Lines() []string {
+	return slices.DeleteFunc(
+		[]string{address.Line1, address.Line2, address.Town, address.County, address.Postcode},
+		func(line string) bool { return line == "" },
+	)
+}
+
+// IsValidEmail reports whether the value is a single, bare email address.
+func IsValidEmail(email string) bool {
+	address, err := mail.ParseAddress(email)
+
+	return err == nil && address.Address == email
 }
 
 type CustomerList struct {
+	Version   int        `json:"version"`
 	Customers []Customer `json:"customers"`
 }
 
+// Migrations upgrade older customer stores to the current schema, in version order.
+var Migrations = []filehandler.Migration{
+	{
+		Version:     1,
+		Description: "add schema version to customer store",
+		Migrate:     func(map[string]any) error { return nil },
+	},
+	{
+		Version:     2,
+		Description: "assign a unique ID to each customer",
+		Migrate: func(data map[string]any) error {
+			return idhandler.AssignMissing(data["customers"])
+		},
+	},
+}
+
 type CustomerHandler struct {
-	config    *configuration.Config
-	Customers []Customer
+	config       *configuration.Config
+	Customers    []Customer
+	lastModified time.Time
+	fileCipher   *filehandler.Cipher
+	postcodes    map[string]geohandler.Coordinates
+	auditLog     *audithandler.AuditLog
 }
 
 func wrapError(err error) error {
@@ -31,22 +98,125 @@ var errCustomerNotFound = errors.New("specified customer was not found")
 
 var errCustomerAlreadyExists = errors.New("a customer with that username already exists")
 
+var errMissingName = errors.New("customer name cannot be blank")
+
+var errOutsideGridLimits = errors.New("customer location is outside the grid limits")
+
+var errAccountNumberInUse = errors.New("a customer with that account number already exists")
+
+var errInvalidContact = errors.New("customer contacts must have a name and a valid email address, if provided")
+
 func New(config *configuration.Config) (*CustomerHandler, error) {
+	fileCipher, err := config.DataFileCipher()
+	if err != nil {
+		return nil, wrapError(err)
+	}
+
 	// Parse customers on initialisation
-	customers, err := filehandler.ReadFile[CustomerList](config.Customers.FilePath)
+	customers, err := filehandler.ReadVersionedFile[CustomerList](config.Customers.FilePath, Migrations, fileCipher)
+	if err != nil {
+		return nil, wrapError(err)
+	}
+
+	lastModified, err := filehandler.GetModifiedTime(config.Customers.FilePath)
+	if err != nil {
+		return nil, wrapError(err)
+	}
+
+	postcodes := map[string]geohandler.Coordinates{}
+	if config.Projection.PostcodesFilePath != "" {
+		postcodes, err = geohandler.LoadPostcodes(config.Projection.PostcodesFilePath)
+		if err != nil {
+			return nil, wrapError(err)
+		}
+	}
+
+	auditLog, err := audithandler.New(config)
 	if err != nil {
 		return nil, wrapError(err)
 	}
 
 	return &CustomerHandler{
-		config:    config,
-		Customers: customers.Customers,
+		config:       config,
+		Customers:    customers.Customers,
+		lastModified: lastModified,
+		fileCipher:   fileCipher,
+		postcodes:    postcodes,
+		auditLog:     auditLog,
 	}, nil
 }
 
+// Reload re-reads the customer store if it has been changed on disk since it was last read or written,
+// so customers added by another instance of the app are picked up without restarting.
+func (ch *CustomerHandler) Reload() error {
+	modified, err := filehandler.GetModifiedTime(ch.config.Customers.FilePath)
+	if err != nil {
+		return wrapError(err)
+	}
+
+	if modified.Equal(ch.lastModified) {
+		return nil
+	}
+
+	customers, err := filehandler.ReadVersionedFile[CustomerList](ch.config.Customers.FilePath, Migrations, ch.fileCipher)
+	if err != nil {
+		return wrapError(err)
+	}
+
+	ch.Customers = customers.Customers
+	ch.lastModified = modified
+
+	return nil
+}
+
+// Write the stored customer list to the persistent customer store,
+// tracking the new modification time so our own writes don't trigger a reload.
+func (ch *CustomerHandler) save() error {
+	err := filehandler.WriteEncryptedFile(ch.config.Customers.FilePath, CustomerList{
+		Version:   filehandler.LatestVersion(Migrations),
+		Customers: ch.Customers,
+	}, ch.fileCipher)
+	if err != nil {
+		return wrapError(err)
+	}
+
+	ch.lastModified, err = filehandler.GetModifiedTime(ch.config.Customers.FilePath)
+	if err != nil {
+		return wrapError(err)
+	}
+
+	return nil
+}
+
+// Find the index of the customer with the given ID in the stored customer list.
+func (ch *CustomerHandler) indexOf(id string) int {
+	return slices.IndexFunc(ch.Customers, func(E Customer) bool {
+		return E.ID == id
+	})
+}
+
+func (ch *CustomerHandler) GetCustomerByID(id string) (*Customer, error) {
+	err := ch.Reload()
+	if err != nil {
+		return nil, err
+	}
+
+	customerIdx := ch.indexOf(id)
+	if customerIdx == -1 {
+		return nil, wrapError(errCustomerNotFound)
+	}
+
+	return &ch.Customers[customerIdx], nil
+}
+
 func (ch *CustomerHandler) GetCustomer(name string) (*Customer, error) {
+	err := ch.Reload()
+	if err != nil {
+		return nil, err
+	}
+
 	customerIdx := slices.IndexFunc(ch.Customers, func(E Customer) bool {
-		return E.Name == name
+		return E.Name == name && !E.IsDeleted()
 	})
 	if customerIdx == -1 {
 		return nil, wrapError(errCustomerNotFound)
@@ -55,42 +225,159 @@ func (ch *CustomerHandler) GetCustomer(name string) (*Customer, error) {
 	return &ch.Customers[customerIdx], nil
 }
 
-func (ch *CustomerHandler) AddCustomer(customer Customer) error {
-	// Check customer name is unique
+// Check a new or updated customer has valid details, and doesn't share a name or account number with another customer.
+func (ch *CustomerHandler) validateCustomer(customer Customer) error {
+	if customer.Name == "" {
+		return wrapError(errMissingName)
+	}
+
+	limits := ch.config.GridLimits
+	if customer.GridX < limits.MinX || customer.GridX > limits.MaxX ||
+		customer.GridY < limits.MinY || customer.GridY > limits.MaxY {
+		return wrapError(errOutsideGridLimits)
+	}
+
+	// Check customer name is unique, ignoring the customer being updated
 	customerIdx := slices.IndexFunc(ch.Customers, func(E Customer) bool {
-		return E.Name == customer.Name
+		return E.Name == customer.Name && E.ID != customer.ID && !E.IsDeleted()
 	})
 	if customerIdx != -1 {
 		return wrapError(errCustomerAlreadyExists)
 	}
 
+	// Check account number is unique, if provided
+	if customer.AccountNumber != "" {
+		customerIdx = slices.IndexFunc(ch.Customers, func(E Customer) bool {
+			return E.AccountNumber == customer.AccountNumber && E.ID != customer.ID && !E.IsDeleted()
+		})
+		if customerIdx != -1 {
+			return wrapError(errAccountNumberInUse)
+		}
+	}
+
+	for _, contact := range customer.Contacts {
+		if contact.Name == "" || (contact.Email != "" && !IsValidEmail(contact.Email)) {
+			return wrapError(errInvalidContact)
+		}
+	}
+
+	err := validatePricing(customer.Pricing)
+	if err != nil {
+		return err
+	}
+
+	return ch.validateRegion(customer.Region)
+}
+
+func (ch *CustomerHandler) AddCustomer(customer Customer, addedBy string) error {
+	// Pick up any changes from other processes before modifying the store
+	err := ch.Reload()
+	if err != nil {
+		return err
+	}
+
+	// IDs are only ever assigned here, never by the caller
+	customer.ID = ""
+	customer.Tags = NormaliseTags(customer.Tags)
+	customer.Region = ch.normaliseRegion(customer.Region)
+
+	err = ch.applyCoordinates(&customer)
+	if err != nil {
+		return err
+	}
+
+	err = ch.validateCustomer(customer)
+	if err != nil {
+		return err
+	}
+
+	// Assign an immutable ID, used to reference the customer from other records
+	customer.ID, err = idhandler.Generate()
+	if err != nil {
+		return wrapError(err)
+	}
+
 	// Update stored customer list
 	ch.Customers = append(ch.Customers, customer)
 
 	// Update persistent customer store
-	err := filehandler.WriteFile(ch.config.Customers.FilePath, CustomerList{Customers: ch.Customers})
+	err = ch.save()
 	if err != nil {
-		return wrapError(err)
+		return err
 	}
 
-	return nil
+	return ch.audit(audithandler.ActionCustomerAdd, addedBy, "", nil, &customer)
+}
+
+// UpdateCustomer replaces the stored details of the customer with the same ID.
+func (ch *CustomerHandler) UpdateCustomer(customer Customer, updatedBy string) error {
+	// Pick up any changes from other processes before modifying the store
+	err := ch.Reload()
+	if err != nil {
+		return err
+	}
+
+	index := ch.indexOf(customer.ID)
+	if index == -1 || ch.Customers[index].IsDeleted() {
+		return wrapError(errCustomerNotFound)
+	}
+
+	// Deletion can only be changed through RemoveCustomer and RestoreCustomer
+	customer.DeletedAt = nil
+	customer.DeletedBy = ""
+	customer.Tags = NormaliseTags(customer.Tags)
+	customer.Region = ch.normaliseRegion(customer.Region)
+
+	err = ch.applyCoordinates(&customer)
+	if err != nil {
+		return err
+	}
+
+	err = ch.validateCustomer(customer)
+	if err != nil {
+		return err
+	}
+
+	before := ch.Customers[index]
+
+	// Update stored customer list
+	ch.Customers[index] = customer
+
+	// Update persistent customer store
+	err = ch.save()
+	if err != nil {
+		return err
+	}
+
+	return ch.audit(audithandler.ActionCustomerUpdate, updatedBy, "", &before, &customer)
 }
 
-func (ch *CustomerHandler) RemoveCustomer(customer Customer) error {
+// RemoveCustomer moves the customer to the recycle bin, recording when and by whom it was deleted.
+// It can be restored, or purged once the retention period has passed.
+func (ch *CustomerHandler) RemoveCustomer(id string, deletedBy string) error {
+	// Pick up any changes from other processes before modifying the store
+	err := ch.Reload()
+	if err != nil {
+		return err
+	}
+
 	// Find index of customer in stored list
-	index := slices.Index(ch.Customers, customer)
-	if index == -1 {
+	index := ch.indexOf(id)
+	if index == -1 || ch.Customers[index].IsDeleted() {
 		return wrapError(errCustomerNotFound)
 	}
 
-	// Crop the customer out of the stored customer list
-	ch.Customers = append(ch.Customers[:index], ch.Customers[index+1:]...)
+	before := ch.Customers[index]
+
+	deletedAt := time.Now()
+	ch.Customers[index].DeletedAt = &deletedAt
+	ch.Customers[index].DeletedBy = deletedBy
 
 	// Update persistent customer store
-	err := filehandler.WriteFile(ch.config.Customers.FilePath, CustomerList{Customers: ch.Customers})
+	err = ch.save()
 	if err != nil {
-		return wrapError(err)
+		return err
 	}
 
-	return nil
+	return ch.audit(audithandler.ActionCustomerRemove, deletedBy, "", &before, &ch.Customers[index])
 }
@@ -0,0 +1,52 @@
+package customerhandler
+
+import (
+	"path/filepath"
+	"testing"
+	"work-mini-project/pkg/configuration"
+	filehandler "work-mini-project/pkg/fileHandler"
+)
+
+// Create a customer handler on a temporary store holding the given customers, with a 0-50 grid and two regions.
+func newTestHandler(t *testing.T, customers ...Customer) *CustomerHandler {
+	t.Helper()
+
+	directory := t.TempDir()
+
+	config := &configuration.Config{
+		Customers:  configuration.CustomerConfig{FilePath: filepath.Join(directory, "customers.json")},
+		Audit:      configuration.AuditConfig{FilePath: filepath.Join(directory, "audit.jsonl")},
+		GridLimits: configuration.GridLimitsConfig{MinX: 0, MaxX: 50, MinY: 0, MaxY: 50},
+		Regions: []configuration.RegionConfig{
+			{Name: "North", Rectangle: &configuration.GridLimitsConfig{MinX: 0, MaxX: 50, MinY: 30, MaxY: 50}},
+			{Name: "Docks", Polygon: [][2]int{{0, 0}, {20, 0}, {0, 20}}},
+		},
+	}
+
+	err := filehandler.WriteFile(config.Customers.FilePath, CustomerList{
+		Version:   filehandler.LatestVersion(Migrations),
+		Customers: customers,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ch, err := New(config)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	return ch
+}
+
+// Read the customers back from the store on disk, rather than the handler's copy.
+func storedCustomers(t *testing.T, ch *CustomerHandler) []Customer {
+	t.Helper()
+
+	list, err := filehandler.ReadFile[CustomerList](ch.config.Customers.FilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return list.Customers
+}
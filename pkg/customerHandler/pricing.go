@@ -0,0 +1,73 @@
+package customerhandler
+
+import (
+	"errors"
+	audithandler "work-mini-project/pkg/auditHandler"
+)
+
+const MaxDiscountPercent = 100
+
+// RateCard replaces the list price of a transport method with a fixed charge plus a rate per unit of distance.
+type RateCard struct {
+	BaseCharge      float64 `json:"baseCharge"`
+	PerDistanceUnit float64 `json:"perDistanceUnit"`
+}
+
+// PricingAgreement holds rates negotiated with a customer, applied on top of the list price of each trip.
+type PricingAgreement struct {
+	DiscountPercent float64             `json:"discountPercent,omitempty"`
+	Surcharge       float64             `json:"surcharge,omitempty"`
+	MinimumCharge   float64             `json:"minimumCharge,omitempty"`
+	RateCards       map[string]RateCard `json:"rateCards,omitempty"` // Keyed by transport method
+}
+
+var errInvalidPricing = errors.New("pricing agreement values must not be negative, and discounts must not exceed 100%")
+
+func validatePricing(pricing *PricingAgreement) error {
+	if pricing == nil {
+		return nil
+	}
+
+	if pricing.DiscountPercent < 0 || pricing.DiscountPercent > MaxDiscountPercent ||
+		pricing.Surcharge < 0 || pricing.MinimumCharge < 0 {
+		return wrapError(errInvalidPricing)
+	}
+
+	for _, rateCard := range pricing.RateCards {
+		if rateCard.BaseCharge < 0 || rateCard.PerDistanceUnit < 0 {
+			return wrapError(errInvalidPricing)
+		}
+	}
+
+	return nil
+}
+
+// SetPricing replaces the pricing agreement of a customer, or removes it if nil.
+func (ch *CustomerHandler) SetPricing(id string, pricing *PricingAgreement, setBy string) error {
+	// Pick up any changes from other processes before modifying the store
+	err := ch.Reload()
+	if err != nil {
+		return err
+	}
+
+	index := ch.indexOf(id)
+	if index == -1 || ch.Customers[index].IsDeleted() {
+		return wrapError(errCustomerNotFound)
+	}
+
+	err = validatePricing(pricing)
+	if err != nil {
+		return err
+	}
+
+	before := ch.Customers[index]
+	ch.Customers[index].Pricing = pricing
+
+	// Update persistent customer store
+	err = ch.save()
+	if err != nil {
+		return err
+	}
+
+	return ch.audit(audithandler.ActionCustomerUpdate, setBy, "pricing", &before, &ch.Customers[index])
+}
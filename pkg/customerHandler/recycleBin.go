@@ -0,0 +1,149 @@
+package customerhandler
+
+import (
+	"errors"
+	"slices"
+	"time"
+	audithandler "work-mini-project/pkg/auditHandler"
+)
+
+var errCustomerNotDeleted = errors.New("specified customer is not in the recycle bin")
+
+var errRetentionPeriod = errors.New("customer cannot be purged until the retention period has passed")
+
+func (customer Customer) IsDeleted() bool {
+	return customer.DeletedAt != nil
+}
+
+// ActiveCustomers returns the customers that are not in the recycle bin.
+func (ch *CustomerHandler) ActiveCustomers() []Customer {
+	return slices.DeleteFunc(slices.Clone(ch.Customers), Customer.IsDeleted)
+}
+
+// DeletedCustomers returns the customers in the recycle bin.
+func (ch *CustomerHandler) DeletedCustomers() []Customer {
+	return slices.DeleteFunc(slices.Clone(ch.Customers), func(customer Customer) bool {
+		return !customer.IsDeleted()
+	})
+}
+
+// PurgeableAt returns when a deleted customer's retention period ends.
+func (ch *CustomerHandler) PurgeableAt(customer Customer) time.Time {
+	if !customer.IsDeleted() {
+		return time.Time{}
+	}
+
+	return customer.DeletedAt.AddDate(0, 0, ch.config.RecycleBin.RetentionDays)
+}
+
+func (ch *CustomerHandler) canPurge(customer Customer) bool {
+	return customer.IsDeleted() && !time.Now().Before(ch.PurgeableAt(customer))
+}
+
+// RestoreCustomer takes a customer out of the recycle bin,
+// as long as its name and account number haven't since been reused.
+func (ch *CustomerHandler) RestoreCustomer(id string, restoredBy string) error {
+	// Pick up any changes from other processes before modifying the store
+	err := ch.Reload()
+	if err != nil {
+		return err
+	}
+
+	index := ch.indexOf(id)
+	if index == -1 {
+		return wrapError(errCustomerNotFound)
+	}
+
+	if !ch.Customers[index].IsDeleted() {
+		return wrapError(errCustomerNotDeleted)
+	}
+
+	restored := ch.Customers[index]
+	restored.DeletedAt = nil
+	restored.DeletedBy = ""
+
+	err = ch.validateCustomer(restored)
+	if err != nil {
+		return err
+	}
+
+	before := ch.Customers[index]
+	ch.Customers[index] = restored
+
+	// Update persistent customer store
+	err = ch.save()
+	if err != nil {
+		return err
+	}
+
+	return ch.audit(audithandler.ActionCustomerRestore, restoredBy, "", &before, &restored)
+}
+
+// PurgeCustomer permanently removes a customer from the recycle bin, once its retention period has passed.
+func (ch *CustomerHandler) PurgeCustomer(id string, purgedBy string) error {
+	// Pick up any changes from other processes before modifying the store
+	err := ch.Reload()
+	if err != nil {
+		return err
+	}
+
+	index := ch.indexOf(id)
+	if index == -1 {
+		return wrapError(errCustomerNotFound)
+	}
+
+	if !ch.Customers[index].IsDeleted() {
+		return wrapError(errCustomerNotDeleted)
+	}
+
+	if !ch.canPurge(ch.Customers[index]) {
+		return wrapError(errRetentionPeriod)
+	}
+
+	purged := ch.Customers[index]
+
+	// Crop the customer out of the stored customer list
+	ch.Customers = append(ch.Customers[:index], ch.Customers[index+1:]...)
+
+	// Update persistent customer store
+	err = ch.save()
+	if err != nil {
+		return err
+	}
+
+	return ch.audit(audithandler.ActionCustomerPurge, purgedBy, "", &purged, nil)
+}
+
+// PurgeExpiredCustomers permanently removes every customer whose retention period has passed,
+// returning the number removed.
+func (ch *CustomerHandler) PurgeExpiredCustomers(purgedBy string) (int, error) {
+	// Pick up any changes from other processes before modifying the store
+	err := ch.Reload()
+	if err != nil {
+		return 0, err
+	}
+
+	purged := slices.DeleteFunc(slices.Clone(ch.Customers), func(customer Customer) bool {
+		return !ch.canPurge(customer)
+	})
+	if len(purged) == 0 {
+		return 0, nil
+	}
+
+	ch.Customers = slices.DeleteFunc(ch.Customers, ch.canPurge)
+
+	// Update persistent customer store
+	err = ch.save()
+	if err != nil {
+		return 0, err
+	}
+
+	for _, customer := range purged {
+		err = ch.audit(audithandler.ActionCustomerPurge, purgedBy, "expired", &customer, nil)
+		if err != nil {
+			return len(purged), err
+		}
+	}
+
+	return len(purged), nil
+}
@@ -0,0 +1,170 @@
+package customerhandler
+
+import (
+	"errors"
+	"slices"
+	"sort"
+	"strings"
+	"work-mini-project/pkg/configuration"
+)
+
+// Name used to group customers outside of every configured region.
+const UnassignedRegion = "Unassigned"
+
+var errUnknownRegion = errors.New("customer region is not one of the configured regions")
+
+// NormaliseTags trims, lower-cases and de-duplicates tags, dropping any that are blank.
+func NormaliseTags(tags []string) []string {
+	normalised := []string{}
+
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag != "" && !slices.Contains(normalised, tag) {
+			normalised = append(normalised, tag)
+		}
+	}
+
+	return normalised
+}
+
+func (customer Customer) HasTag(tag string) bool {
+	return slices.Contains(customer.Tags, strings.ToLower(strings.TrimSpace(tag)))
+}
+
+// RegionNames returns the names of the configured regions, in config order.
+func (ch *CustomerHandler) RegionNames() []string {
+	names := make([]string, len(ch.config.Regions))
+	for i, region := range ch.config.Regions {
+		names[i] = region.Name
+	}
+
+	return names
+}
+
+// RegionOf returns the region a customer belongs to. An explicitly assigned region takes priority,
+// otherwise it is the first configured region containing the customer's location.
+func (ch *CustomerHandler) RegionOf(customer Customer) string {
+	if customer.Region != "" {
+		return customer.Region
+	}
+
+	for _, region := range ch.config.Regions {
+		if regionContains(region, customer.GridX, customer.GridY) {
+			return region.Name
+		}
+	}
+
+	return UnassignedRegion
+}
+
+// GroupByRegion returns customers keyed by region name, along with the region names in display order.
+func (ch *CustomerHandler) GroupByRegion() ([]string, map[string][]Customer) {
+	groups := map[string][]Customer{}
+	for _, customer := range ch.ActiveCustomers() {
+		region := ch.RegionOf(customer)
+		groups[region] = append(groups[region], customer)
+	}
+
+	names := []string{}
+
+	for _, name := range append(ch.RegionNames(), UnassignedRegion) {
+		if _, ok := groups[name]; ok {
+			names = append(names, name)
+		}
+	}
+
+	return names, groups
+}
+
+// GroupByTag returns customers keyed by tag, along with the tags in alphabetical order.
+func (ch *CustomerHandler) GroupByTag() ([]string, map[string][]Customer) {
+	groups := map[string][]Customer{}
+	for _, customer := range ch.ActiveCustomers() {
+		for _, tag := range customer.Tags {
+			groups[tag] = append(groups[tag], customer)
+		}
+	}
+
+	tags := make([]string, 0, len(groups))
+	for tag := range groups {
+		tags = append(tags, tag)
+	}
+
+	sort.Strings(tags)
+
+	return tags, groups
+}
+
+// Region names are matched ignoring case, as in the region: filter.
+func (ch *CustomerHandler) findRegion(region string) int {
+	return slices.IndexFunc(ch.RegionNames(), func(name string) bool {
+		return strings.EqualFold(name, strings.TrimSpace(region))
+	})
+}
+
+// Spell an assigned region as it is in config, so customers group together whatever case it was entered in.
+func (ch *CustomerHandler) normaliseRegion(region string) string {
+	index := ch.findRegion(region)
+	if index == -1 {
+		return region
+	}
+
+	return ch.RegionNames()[index]
+}
+
+func (ch *CustomerHandler) validateRegion(region string) error {
+	if region != "" && ch.findRegion(region) == -1 {
+		return wrapError(errUnknownRegion)
+	}
+
+	return nil
+}
+
+// Check whether a grid location is within a region. Locations on the boundary are inside.
+func regionContains(region configuration.RegionConfig, x int, y int) bool {
+	if region.Rectangle != nil {
+		return x >= region.Rectangle.MinX && x <= region.Rectangle.MaxX &&
+			y >= region.Rectangle.MinY && y <= region.Rectangle.MaxY
+	}
+
+	return polygonContains(region.Polygon, x, y)
+}
+
+// Ray casting point in polygon test, treating points on an edge as inside.
+func polygonContains(polygon [][2]int, x int, y int) bool {
+	if len(polygon) < 3 {
+		return false
+	}
+
+	inside := false
+
+	for i := range polygon {
+		start, end := polygon[i], polygon[(i+1)%len(polygon)]
+
+		if onSegment(start, end, x, y) {
+			return true
+		}
+
+		// Count edges crossed by a ray cast from the point in the positive X direction
+		if (start[1] > y) != (end[1] > y) {
+			crossingX := float64(start[0]) +
+				float64(y-start[1])*float64(end[0]-start[0])/float64(end[1]-start[1])
+
+			if float64(x) < crossingX {
+				inside = !inside
+			}
+		}
+	}
+
+	return inside
+}
+
+func onSegment(start [2]int, end [2]int, x int, y int) bool {
+	crossProduct := (end[0]-start[0])*(y-start[1]) - (end[1]-start[1])*(x-start[0])
+	if crossProduct != 0 {
+		return false
+	}
+
+	return x >= min(start[0], end[0]) && x <= max(start[0], end[0]) &&
+		y >= min(start[1], end[1]) && y <= max(start[1], end[1])
+}
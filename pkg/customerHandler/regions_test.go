@@ -0,0 +1,89 @@
+package customerhandler
+
+import (
+	"testing"
+	"work-mini-project/pkg/configuration"
+)
+
+func TestRegionContains(t *testing.T) {
+	rectangle := configuration.RegionConfig{
+		Name: "Rectangle", Rectangle: &configuration.GridLimitsConfig{MinX: 10, MaxX: 20, MinY: -5, MaxY: 5},
+	}
+
+	// An L shape, so the notch at the top right is outside despite being within the polygon's bounds
+	lShape := configuration.RegionConfig{
+		Name: "L", Polygon: [][2]int{{0, 0}, {10, 0}, {10, 5}, {5, 5}, {5, 10}, {0, 10}},
+	}
+
+	tests := []struct {
+		name   string
+		region configuration.RegionConfig
+		x, y   int
+		want   bool
+	}{
+		{"rectangle inside", rectangle, 15, 0, true},
+		{"rectangle corner", rectangle, 10, -5, true},
+		{"rectangle edge", rectangle, 20, 3, true},
+		{"rectangle left of", rectangle, 9, 0, false},
+		{"rectangle above", rectangle, 15, 6, false},
+		{"polygon inside lower arm", lShape, 7, 3, true},
+		{"polygon inside upper arm", lShape, 2, 8, true},
+		{"polygon in the notch", lShape, 7, 7, false},
+		{"polygon vertex", lShape, 0, 0, true},
+		{"polygon inner corner", lShape, 5, 5, true},
+		{"polygon horizontal edge", lShape, 7, 5, true},
+		{"polygon vertical edge", lShape, 10, 3, true},
+		{"polygon level with a vertex", lShape, 3, 5, true},
+		{"polygon right of", lShape, 11, 0, false},
+		{"polygon below", lShape, 3, -1, false},
+		{"polygon with too few vertices", configuration.RegionConfig{Polygon: [][2]int{{0, 0}, {10, 10}}}, 5, 5, false},
+	}
+
+	for _, test := range tests {
+		if got := regionContains(test.region, test.x, test.y); got != test.want {
+			t.Errorf("%s: regionContains(%d, %d) = %t, want %t", test.name, test.x, test.y, got, test.want)
+		}
+	}
+}
+
+func TestRegionOf(t *testing.T) {
+	ch := newTestHandler(t)
+
+	tests := []struct {
+		name     string
+		customer Customer
+		want     string
+	}{
+		{"in rectangle", Customer{GridX: 25, GridY: 40}, "North"},
+		{"in polygon", Customer{GridX: 5, GridY: 5}, "Docks"},
+		{"on polygon's diagonal edge", Customer{GridX: 10, GridY: 10}, "Docks"},
+		{"outside every region", Customer{GridX: 40, GridY: 10}, UnassignedRegion},
+		{"assigned region takes priority", Customer{GridX: 25, GridY: 40, Region: "Docks"}, "Docks"},
+	}
+
+	for _, test := range tests {
+		if got := ch.RegionOf(test.customer); got != test.want {
+			t.Errorf("%s: RegionOf() = %q, want %q", test.name, got, test.want)
+		}
+	}
+}
+
+func TestNormaliseRegion(t *testing.T) {
+	ch := newTestHandler(t)
+
+	tests := []struct {
+		region string
+		want   string
+	}{
+		{"north", "North"},
+		{" DOCKS ", "Docks"},
+		{"Harbour", "Harbour"}, // Unknown regions are left alone, for validateRegion to reject
+		{"", ""},
+	}
+
+	for _, test := range tests {
+		if got := ch.normaliseRegion(test.region); got != test.want {
+			t.Errorf("normaliseRegion(%q) = %q, want %q", test.region, got, test.want)
+		}
+	}
+}
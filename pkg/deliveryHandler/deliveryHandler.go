@@ -0,0 +1,228 @@
+package deliveryhandler
+
+import (
+	"fmt"
+	"slices"
+	"time"
+	audithandler "work-mini-project/pkg/auditHandler"
+	"work-mini-project/pkg/configuration"
+	filehandler "work-mini-project/pkg/fileHandler"
+	idhandler "work-mini-project/pkg/idHandler"
+)
+
+// Delivery records a trip booked to a customer, with the quote it was booked at.
+type Delivery struct {
+	ID         string        `json:"id"`
+	CustomerID string        `json:"customerId"`
+	Method     string        `json:"method"`
+	Distance   float64       `json:"distance"`
+	Duration   time.Duration `json:"duration"` // Lead time from booking to delivery
+	Cost       float64       `json:"cost"`
+	ListCost   float64       `json:"listCost"`
+	BookedAt   time.Time     `json:"bookedAt"`
+	BookedBy   string        `json:"bookedBy"` // ID of the user who booked the delivery
+}
+
+type DeliveryList struct {
+	Version    int        `json:"version"`
+	Deliveries []Delivery `json:"deliveries"`
+}
+
+// Migrations upgrade older delivery stores to the current schema, in version order.
+var Migrations = []filehandler.Migration{
+	{
+		Version:     1,
+		Description: "add schema version to delivery store",
+		Migrate:     func(map[string]any) error { return nil },
+	},
+}
+
+type DeliveryHandler struct {
+	config       *configuration.Config
+	Deliveries   []Delivery
+	lastModified time.Time
+	fileCipher   *filehandler.Cipher
+	auditLog     *audithandler.AuditLog
+}
+
+// CustomerSummary holds statistics computed from the deliveries made to a customer.
+type CustomerSummary struct {
+	DeliveryCount   int
+	TotalSpend      float64
+	MethodCounts    map[string]int
+	PreferredMethod string
+	AverageLeadTime time.Duration
+	LastDelivery    time.Time
+}
+
+func wrapError(err error) error {
+	return fmt.Errorf("deliveryHandler: %w", err)
+}
+
+func New(config *configuration.Config) (*DeliveryHandler, error) {
+	fileCipher, err := config.DataFileCipher()
+	if err != nil {
+		return nil, wrapError(err)
+	}
+
+	// Parse deliveries on initialisation
+	deliveries, err := filehandler.ReadVersionedFile[DeliveryList](config.Deliveries.FilePath, Migrations, fileCipher)
+	if err != nil {
+		return nil, wrapError(err)
+	}
+
+	lastModified, err := filehandler.GetModifiedTime(config.Deliveries.FilePath)
+	if err != nil {
+		return nil, wrapError(err)
+	}
+
+	auditLog, err := audithandler.New(config)
+	if err != nil {
+		return nil, wrapError(err)
+	}
+
+	return &DeliveryHandler{
+		config:       config,
+		Deliveries:   deliveries.Deliveries,
+		lastModified: lastModified,
+		fileCipher:   fileCipher,
+		auditLog:     auditLog,
+	}, nil
+}
+
+// Reload re-reads the delivery store if it has been changed on disk since it was last read or written,
+// so deliveries booked by another instance of the app are picked up without restarting.
+func (dh *DeliveryHandler) Reload() error {
+	modified, err := filehandler.GetModifiedTime(dh.config.Deliveries.FilePath)
+	if err != nil {
+		return wrapError(err)
+	}
+
+	if modified.Equal(dh.lastModified) {
+		return nil
+	}
+
+	deliveries, err := filehandler.ReadVersionedFile[DeliveryList](
+		dh.config.Deliveries.FilePath, Migrations, dh.fileCipher,
+	)
+	if err != nil {
+		return wrapError(err)
+	}
+
+	dh.Deliveries = deliveries.Deliveries
+	dh.lastModified = modified
+
+	return nil
+}
+
+// Write the stored delivery list to the persistent delivery store,
+// tracking the new modification time so our own writes don't trigger a reload.
+func (dh *DeliveryHandler) save() error {
+	err := filehandler.WriteEncryptedFile(dh.config.Deliveries.FilePath, DeliveryList{
+		Version:    filehandler.LatestVersion(Migrations),
+		Deliveries: dh.Deliveries,
+	}, dh.fileCipher)
+	if err != nil {
+		return wrapError(err)
+	}
+
+	dh.lastModified, err = filehandler.GetModifiedTime(dh.config.Deliveries.FilePath)
+	if err != nil {
+		return wrapError(err)
+	}
+
+	return nil
+}
+
+// RecordDelivery stores a booked delivery, assigning its ID and booking time.
+func (dh *DeliveryHandler) RecordDelivery(delivery Delivery) (Delivery, error) {
+	// Pick up any changes from other processes before modifying the store
+	err := dh.Reload()
+	if err != nil {
+		return Delivery{}, err
+	}
+
+	delivery.ID, err = idhandler.Generate()
+	if err != nil {
+		return Delivery{}, wrapError(err)
+	}
+
+	delivery.BookedAt = time.Now()
+
+	// Update stored delivery list
+	dh.Deliveries = append(dh.Deliveries, delivery)
+
+	// Update persistent delivery store
+	err = dh.save()
+	if err != nil {
+		return Delivery{}, err
+	}
+
+	// Only IDs and the method are recorded, keeping prices out of the log
+	err = dh.auditLog.Record(audithandler.Entry{
+		ActorID:  delivery.BookedBy,
+		Action:   audithandler.ActionDeliveryBook,
+		TargetID: delivery.CustomerID,
+		Detail:   delivery.Method + " delivery " + delivery.ID,
+	}, nil, nil)
+	if err != nil {
+		// The delivery is booked, even though recording it failed
+		return delivery, wrapError(err)
+	}
+
+	return delivery, nil
+}
+
+// ForCustomer returns the deliveries made to a customer, most recent first.
+func (dh *DeliveryHandler) ForCustomer(customerID string) ([]Delivery, error) {
+	err := dh.Reload()
+	if err != nil {
+		return nil, err
+	}
+
+	deliveries := slices.DeleteFunc(slices.Clone(dh.Deliveries), func(delivery Delivery) bool {
+		return delivery.CustomerID != customerID
+	})
+
+	slices.SortStableFunc(deliveries, func(a, b Delivery) int {
+		return b.BookedAt.Compare(a.BookedAt)
+	})
+
+	return deliveries, nil
+}
+
+// Summarise computes spend, preferred transport method and average lead time over a set of deliveries.
+func Summarise(deliveries []Delivery) CustomerSummary {
+	summary := CustomerSummary{
+		DeliveryCount: len(deliveries),
+		MethodCounts:  map[string]int{},
+	}
+
+	if len(deliveries) == 0 {
+		return summary
+	}
+
+	var totalLeadTime time.Duration
+
+	for _, delivery := range deliveries {
+		summary.TotalSpend += delivery.Cost
+		summary.MethodCounts[delivery.Method]++
+		totalLeadTime += delivery.Duration
+
+		if delivery.BookedAt.After(summary.LastDelivery) {
+			summary.LastDelivery = delivery.BookedAt
+		}
+	}
+
+	summary.AverageLeadTime = totalLeadTime / time.Duration(len(deliveries))
+
+	// Preferred method is the most used, ties broken alphabetically so the result is stable
+	for method, count := range summary.MethodCounts {
+		preferredCount := summary.MethodCounts[summary.PreferredMethod]
+		if count > preferredCount || (count == preferredCount && method < summary.PreferredMethod) {
+			summary.PreferredMethod = method
+		}
+	}
+
+	return summary
+}
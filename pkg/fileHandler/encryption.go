@@ -0,0 +1,204 @@
+package filehandler
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Algorithm recorded in encrypted data files, used to tell them apart from plaintext JSON.
+const encryptionAlgorithm = "AES-256-GCM"
+
+const encryptionKeyLength = 32
+
+// Cipher encrypts and decrypts data files with AES-GCM.
+// A nil *Cipher reads and writes plaintext files.
+type Cipher struct {
+	aead cipher.AEAD
+}
+
+// Layout of an encrypted data file on disk. Byte slices are base64 encoded by encoding/json.
+type encryptedFile struct {
+	Algorithm  string `json:"algorithm"`
+	Nonce      []byte `json:"nonce"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+var errMissingKey = errors.New("data file is encrypted but no encryption key was provided")
+
+var errInvalidKey = errors.New("encryption key must be 32 bytes, base64 encoded")
+
+var errKeyNotBase64 = errors.New("key must be base64 encoded")
+
+var errDecryptionFailed = errors.New("unable to decrypt data file, check the encryption key")
+
+func NewCipher(key []byte) (*Cipher, error) {
+	if len(key) != encryptionKeyLength {
+		return nil, wrapError(errInvalidKey)
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, wrapError(err)
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, wrapError(err)
+	}
+
+	return &Cipher{aead: aead}, nil
+}
+
+// LoadKey reads a base64 encoded key from the environment variable if set, otherwise from the key file.
+// Returns nil if neither provides a key.
+func LoadKey(keyEnvVar string, keyFilePath string) ([]byte, error) {
+	encodedKey := ""
+	if keyEnvVar != "" {
+		encodedKey = os.Getenv(keyEnvVar)
+	}
+
+	if encodedKey == "" && keyFilePath != "" {
+		fileContent, err := os.ReadFile(keyFilePath)
+		if err != nil {
+			return nil, wrapError(err)
+		}
+
+		encodedKey = string(fileContent)
+	}
+
+	if encodedKey == "" {
+		//nolint:nilnil // nil key means none was configured, which callers may allow
+		return nil, nil
+	}
+
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encodedKey))
+	if err != nil {
+		return nil, wrapError(errKeyNotBase64)
+	}
+
+	return key, nil
+}
+
+// LoadCipher creates a Cipher from a base64 encoded key,
+// taken from the environment variable if set, otherwise from the key file.
+func LoadCipher(keyEnvVar string, keyFilePath string) (*Cipher, error) {
+	key, err := LoadKey(keyEnvVar, keyFilePath)
+	if err != nil {
+		return nil, err
+	}
+
+	if key == nil {
+		return nil, wrapError(errMissingKey)
+	}
+
+	return NewCipher(key)
+}
+
+func (c *Cipher) encrypt(plaintext []byte) ([]byte, error) {
+	nonce := make([]byte, c.aead.NonceSize())
+
+	_, err := rand.Read(nonce)
+	if err != nil {
+		return nil, wrapError(err)
+	}
+
+	encrypted, err := json.MarshalIndent(encryptedFile{
+		Algorithm:  encryptionAlgorithm,
+		Nonce:      nonce,
+		Ciphertext: c.aead.Seal(nil, nonce, plaintext, nil),
+	}, "", "    ")
+	if err != nil {
+		return nil, wrapError(err)
+	}
+
+	return encrypted, nil
+}
+
+func (c *Cipher) decrypt(file *encryptedFile) ([]byte, error) {
+	plaintext, err := c.aead.Open(nil, file.Nonce, file.Ciphertext, nil)
+	if err != nil {
+		return nil, wrapError(errDecryptionFailed)
+	}
+
+	return plaintext, nil
+}
+
+// Parse file content as an encrypted data file, returning nil if it is plaintext JSON.
+func parseEncryptedFile(fileContent []byte) *encryptedFile {
+	var file encryptedFile
+
+	err := json.Unmarshal(fileContent, &file)
+	if err != nil || file.Algorithm != encryptionAlgorithm {
+		return nil
+	}
+
+	return &file
+}
+
+// Read a file, decrypting it if required. Plaintext files are returned as is,
+// so existing files can still be read once encryption is enabled.
+func readFileContent(filePath string, fileCipher *Cipher) ([]byte, error) {
+	fileContent, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, wrapError(err)
+	}
+
+	file := parseEncryptedFile(fileContent)
+	if file == nil {
+		return fileContent, nil
+	}
+
+	if fileCipher == nil {
+		return nil, wrapError(fmt.Errorf("%s: %w", filePath, errMissingKey))
+	}
+
+	return fileCipher.decrypt(file)
+}
+
+// EncryptFile encrypts an existing data file in place, replacing the file in one step.
+// Returns false if the file was already encrypted.
+func EncryptFile(filePath string, fileCipher *Cipher) (bool, error) {
+	fileContent, err := os.ReadFile(filePath)
+	if err != nil {
+		return false, wrapError(err)
+	}
+
+	if parseEncryptedFile(fileContent) != nil {
+		return false, nil
+	}
+
+	encrypted, err := fileCipher.encrypt(fileContent)
+	if err != nil {
+		return false, err
+	}
+
+	return true, replaceFileContent(filePath, encrypted)
+}
+
+// DecryptFile decrypts an existing data file in place, replacing the file in one step.
+// Returns false if the file was already plaintext.
+func DecryptFile(filePath string, fileCipher *Cipher) (bool, error) {
+	fileContent, err := os.ReadFile(filePath)
+	if err != nil {
+		return false, wrapError(err)
+	}
+
+	file := parseEncryptedFile(fileContent)
+	if file == nil {
+		return false, nil
+	}
+
+	plaintext, err := fileCipher.decrypt(file)
+	if err != nil {
+		return false, err
+	}
+
+	return true, replaceFileContent(filePath, plaintext)
+}
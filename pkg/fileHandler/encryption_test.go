@@ -0,0 +1,172 @@
+package filehandler
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var testKey = bytes.Repeat([]byte{1}, encryptionKeyLength)
+
+func newTestCipher(t *testing.T, key []byte) *Cipher {
+	t.Helper()
+
+	fileCipher, err := NewCipher(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return fileCipher
+}
+
+func TestNewCipher(t *testing.T) {
+	for _, length := range []int{0, 16, encryptionKeyLength - 1, encryptionKeyLength + 1} {
+		_, err := NewCipher(make([]byte, length))
+		if !errors.Is(err, errInvalidKey) {
+			t.Errorf("NewCipher() with a %d byte key error = %v, want %v", length, err, errInvalidKey)
+		}
+	}
+}
+
+func TestLoadKey(t *testing.T) {
+	encodedKey := base64.StdEncoding.EncodeToString(testKey)
+	otherKey := bytes.Repeat([]byte{2}, encryptionKeyLength)
+
+	keyFilePath := filepath.Join(t.TempDir(), "key")
+
+	err := os.WriteFile(keyFilePath, []byte(encodedKey+"\n"), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		envValue    string
+		keyFilePath string
+		want        []byte
+		wantErr     error
+	}{
+		{"from the environment", encodedKey, "", testKey, nil},
+		{"from the key file", "", keyFilePath, testKey, nil},
+		{"environment ahead of the key file", base64.StdEncoding.EncodeToString(otherKey), keyFilePath, otherKey, nil},
+		{"no key", "", "", nil, nil},
+		{"not base64", "not base64!", "", nil, errKeyNotBase64},
+		{"missing key file", "", filepath.Join(t.TempDir(), "missing"), nil, os.ErrNotExist},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Setenv("TEST_DATA_KEY", test.envValue)
+
+			key, err := LoadKey("TEST_DATA_KEY", test.keyFilePath)
+			if !errors.Is(err, test.wantErr) || !bytes.Equal(key, test.want) {
+				t.Errorf("LoadKey() = %v, %v, want %v, %v", key, err, test.want, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestEncryptedFileRoundTrip(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "data.json")
+	written := testList{Version: 1, Items: []string{"secret"}, Count: 1}
+
+	err := WriteEncryptedFile(filePath, written, newTestCipher(t, testKey))
+	if err != nil {
+		t.Fatalf("WriteEncryptedFile() error = %v", err)
+	}
+
+	fileContent, err := os.ReadFile(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if bytes.Contains(fileContent, []byte("secret")) {
+		t.Errorf("encrypted file contains the plaintext: %s", fileContent)
+	}
+
+	read, err := ReadEncryptedFile[testList](filePath, newTestCipher(t, testKey))
+	if err != nil || read.Items[0] != "secret" {
+		t.Fatalf("ReadEncryptedFile() = %+v, %v, want %+v", read, err, written)
+	}
+
+	_, err = ReadEncryptedFile[testList](filePath, nil)
+	if !errors.Is(err, errMissingKey) {
+		t.Errorf("ReadEncryptedFile() without a key error = %v, want %v", err, errMissingKey)
+	}
+
+	_, err = ReadEncryptedFile[testList](filePath, newTestCipher(t, bytes.Repeat([]byte{2}, encryptionKeyLength)))
+	if !errors.Is(err, errDecryptionFailed) {
+		t.Errorf("ReadEncryptedFile() with another key error = %v, want %v", err, errDecryptionFailed)
+	}
+}
+
+func TestEncryptAndDecryptFile(t *testing.T) {
+	directory := t.TempDir()
+	filePath := filepath.Join(directory, "data.json")
+	plaintext := []byte(`{"version": 1, "items": ["secret"]}`)
+	fileCipher := newTestCipher(t, testKey)
+
+	err := os.WriteFile(filePath, plaintext, 0o640)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Converting twice leaves the file as it is, and reports it was already converted
+	for _, wantConverted := range []bool{true, false} {
+		converted, err := EncryptFile(filePath, fileCipher)
+		if err != nil || converted != wantConverted {
+			t.Fatalf("EncryptFile() = %t, %v, want %t", converted, err, wantConverted)
+		}
+	}
+
+	read, err := ReadEncryptedFile[testList](filePath, fileCipher)
+	if err != nil || read.Items[0] != "secret" {
+		t.Fatalf("ReadEncryptedFile() after EncryptFile() = %+v, %v", read, err)
+	}
+
+	// A failed conversion leaves the file untouched
+	encrypted, err := os.ReadFile(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = DecryptFile(filePath, newTestCipher(t, bytes.Repeat([]byte{2}, encryptionKeyLength)))
+	if !errors.Is(err, errDecryptionFailed) {
+		t.Errorf("DecryptFile() with another key error = %v, want %v", err, errDecryptionFailed)
+	}
+
+	fileContent, err := os.ReadFile(filePath)
+	if err != nil || !bytes.Equal(fileContent, encrypted) {
+		t.Error("DecryptFile() with another key changed the file")
+	}
+
+	for _, wantConverted := range []bool{true, false} {
+		converted, err := DecryptFile(filePath, fileCipher)
+		if err != nil || converted != wantConverted {
+			t.Fatalf("DecryptFile() = %t, %v, want %t", converted, err, wantConverted)
+		}
+	}
+
+	fileContent, err = os.ReadFile(filePath)
+	if err != nil || !bytes.Equal(fileContent, plaintext) {
+		t.Errorf("DecryptFile() content = %s, want %s", fileContent, plaintext)
+	}
+
+	// Files are replaced rather than rewritten, so check the permissions were kept and no temporary files remain
+	fileInfo, err := os.Stat(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if fileInfo.Mode().Perm() != 0o640 {
+		t.Errorf("converted file mode = %v, want %v", fileInfo.Mode().Perm(), os.FileMode(0o640))
+	}
+
+	entries, err := os.ReadDir(directory)
+	if err != nil || len(entries) != 1 {
+		t.Errorf("directory holds %d files after converting, want 1", len(entries))
+	}
+}
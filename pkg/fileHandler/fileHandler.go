@@ -1,9 +1,12 @@
 package filehandler
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"os"
+	"path/filepath"
+	"time"
 )
 
 func wrapError(err error) error {
@@ -11,9 +14,14 @@ func wrapError(err error) error {
 }
 
 func ReadFile[T any](filePath string) (*T, error) {
-	fileContent, err := os.ReadFile(filePath)
+	return ReadEncryptedFile[T](filePath, nil)
+}
+
+// ReadEncryptedFile reads a JSON file, decrypting it first if it was written encrypted.
+func ReadEncryptedFile[T any](filePath string, fileCipher *Cipher) (*T, error) {
+	fileContent, err := readFileContent(filePath, fileCipher)
 	if err != nil {
-		return nil, wrapError(err)
+		return nil, err
 	}
 
 	var jsonObject T
@@ -27,19 +35,110 @@ func ReadFile[T any](filePath string) (*T, error) {
 }
 
 func WriteFile(filePath string, jsonObject any) error {
-	file, err := os.OpenFile(filePath, os.O_CREATE|os.O_TRUNC, os.ModePerm)
+	return WriteEncryptedFile(filePath, jsonObject, nil)
+}
+
+// WriteEncryptedFile writes a JSON file, encrypting it if a cipher is provided.
+func WriteEncryptedFile(filePath string, jsonObject any, fileCipher *Cipher) error {
+	var buffer bytes.Buffer
+
+	encoder := json.NewEncoder(&buffer)
+	encoder.SetIndent("", "    ")
+
+	err := encoder.Encode(jsonObject)
+	if err != nil {
+		return wrapError(err)
+	}
+
+	fileContent := buffer.Bytes()
+
+	if fileCipher != nil {
+		fileContent, err = fileCipher.encrypt(fileContent)
+		if err != nil {
+			return err
+		}
+	}
+
+	return writeFileContent(filePath, fileContent)
+}
+
+func writeFileContent(filePath string, fileContent []byte) error {
+	file, err := os.OpenFile(filePath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, os.ModePerm)
 	if err != nil {
 		return wrapError(err)
 	}
 	defer file.Close()
 
-	encoder := json.NewEncoder(file)
-	encoder.SetIndent("", "    ")
+	_, err = file.Write(fileContent)
+	if err != nil {
+		return wrapError(err)
+	}
+
+	return nil
+}
+
+// Replace an existing file's content by writing a temporary file alongside it and renaming it over the original,
+// so an interrupted rewrite leaves either the old or the new content, never a mix. The file's permissions are kept.
+func replaceFileContent(filePath string, fileContent []byte) error {
+	fileInfo, err := os.Stat(filePath)
+	if err != nil {
+		return wrapError(err)
+	}
+
+	file, err := os.CreateTemp(filepath.Dir(filePath), filepath.Base(filePath)+".*.tmp")
+	if err != nil {
+		return wrapError(err)
+	}
+
+	err = writeTempFile(file, fileContent, fileInfo.Mode().Perm())
+	if err != nil {
+		_ = os.Remove(file.Name())
+
+		return err
+	}
 
-	err = encoder.Encode(jsonObject)
+	err = os.Rename(file.Name(), filePath)
 	if err != nil {
+		_ = os.Remove(file.Name())
+
 		return wrapError(err)
 	}
 
 	return nil
 }
+
+// Write and close a temporary file, flushing it to disk so it is complete before it replaces the original.
+func writeTempFile(file *os.File, fileContent []byte, mode os.FileMode) error {
+	_, err := file.Write(fileContent)
+	if err == nil {
+		err = file.Chmod(mode)
+	}
+
+	if err == nil {
+		err = file.Sync()
+	}
+
+	if err != nil {
+		_ = file.Close()
+
+		return wrapError(err)
+	}
+
+	err = file.Close()
+	if err != nil {
+		return wrapError(err)
+	}
+
+	return nil
+}
+
+// GetModifiedTime returns the last modification time of the file, used to detect
+// changes made to data files by other processes.
+func GetModifiedTime(filePath string) (time.Time, error) {
+	fileInfo, err := os.Stat(filePath)
+	if err != nil {
+		return time.Time{}, wrapError(err)
+	}
+
+	return fileInfo.ModTime(), nil
+}
@@ -0,0 +1,158 @@
+package filehandler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// Key holding the schema version in each versioned data file.
+// Files written before versioning was introduced have no key and are treated as version 0.
+const versionKey = "version"
+
+// Migration upgrades the raw contents of a data file from Version-1 to Version.
+type Migration struct {
+	Version     int
+	Description string
+	Migrate     func(data map[string]any) error
+}
+
+// MigrationReport describes the migrations needed to bring a data file up to date.
+type MigrationReport struct {
+	FilePath    string
+	FromVersion int
+	ToVersion   int
+	Steps       []Migration
+}
+
+var errUnsupportedVersion = errors.New("data file was written by a newer version of the app")
+
+var errInvalidVersion = errors.New("data file has an invalid version")
+
+func (report *MigrationReport) String() string {
+	if len(report.Steps) == 0 {
+		return fmt.Sprintf("%s: up to date (version %d)", report.FilePath, report.ToVersion)
+	}
+
+	output := fmt.Sprintf("%s: version %d -> %d", report.FilePath, report.FromVersion, report.ToVersion)
+	for _, step := range report.Steps {
+		output += fmt.Sprintf("\n\t%d - %s", step.Version, step.Description)
+	}
+
+	return output
+}
+
+// LatestVersion returns the schema version a file will be at once all migrations have been applied.
+func LatestVersion(migrations []Migration) int {
+	if len(migrations) == 0 {
+		return 0
+	}
+
+	return migrations[len(migrations)-1].Version
+}
+
+// ReadVersionedFile reads a versioned data file, upgrading it step by step to the latest version.
+// Upgraded files are written back, so each migration only runs once.
+func ReadVersionedFile[T any](filePath string, migrations []Migration, fileCipher *Cipher) (*T, error) {
+	data, err := readRawFile(filePath, fileCipher)
+	if err != nil {
+		return nil, err
+	}
+
+	report, err := planMigrations(filePath, data, migrations)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, step := range report.Steps {
+		err = step.Migrate(data)
+		if err != nil {
+			return nil, wrapError(fmt.Errorf("migrating %s to version %d: %w", filePath, step.Version, err))
+		}
+
+		data[versionKey] = step.Version
+	}
+
+	if len(report.Steps) > 0 {
+		err = WriteEncryptedFile(filePath, data, fileCipher)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Round trip the upgraded contents through JSON to decode into the target type
+	fileContent, err := json.Marshal(data)
+	if err != nil {
+		return nil, wrapError(err)
+	}
+
+	var jsonObject T
+
+	err = json.Unmarshal(fileContent, &jsonObject)
+	if err != nil {
+		return nil, wrapError(err)
+	}
+
+	return &jsonObject, nil
+}
+
+// PlanMigrations reports the migrations that would be applied to a data file, without modifying it.
+func PlanMigrations(filePath string, migrations []Migration, fileCipher *Cipher) (*MigrationReport, error) {
+	data, err := readRawFile(filePath, fileCipher)
+	if err != nil {
+		return nil, err
+	}
+
+	return planMigrations(filePath, data, migrations)
+}
+
+func planMigrations(filePath string, data map[string]any, migrations []Migration) (*MigrationReport, error) {
+	fromVersion, err := fileVersion(data)
+	if err != nil {
+		return nil, wrapError(fmt.Errorf("%s: %w", filePath, err))
+	}
+
+	toVersion := LatestVersion(migrations)
+	if fromVersion > toVersion {
+		return nil, wrapError(fmt.Errorf("%s (version %d): %w", filePath, fromVersion, errUnsupportedVersion))
+	}
+
+	report := &MigrationReport{
+		FilePath:    filePath,
+		FromVersion: fromVersion,
+		ToVersion:   toVersion,
+		Steps:       []Migration{},
+	}
+
+	for _, migration := range migrations {
+		if migration.Version > fromVersion {
+			report.Steps = append(report.Steps, migration)
+		}
+	}
+
+	return report, nil
+}
+
+func readRawFile(filePath string, fileCipher *Cipher) (map[string]any, error) {
+	data, err := ReadEncryptedFile[map[string]any](filePath, fileCipher)
+	if err != nil {
+		return nil, err
+	}
+
+	return *data, nil
+}
+
+func fileVersion(data map[string]any) (int, error) {
+	value, ok := data[versionKey]
+	if !ok {
+		return 0, nil
+	}
+
+	// Numbers are decoded as float64 when unmarshalling into a map
+	version, ok := value.(float64)
+	if !ok || version < 0 || version != float64(int(version)) {
+		return 0, fmt.Errorf("%w: %v", errInvalidVersion, value)
+	}
+
+	return int(version), nil
+}
@@ -0,0 +1,145 @@
+package filehandler
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+type testList struct {
+	Version int      `json:"version"`
+	Items   []string `json:"items"`
+	Count   int      `json:"count"`
+}
+
+// Migrations recording each step run in the data, so tests can check which ran and in what order.
+func testMigrations() []Migration {
+	step := func(name string) func(map[string]any) error {
+		return func(data map[string]any) error {
+			items, _ := data["items"].([]any)
+			data["items"] = append(items, name)
+
+			return nil
+		}
+	}
+
+	return []Migration{
+		{Version: 1, Description: "first", Migrate: step("first")},
+		{Version: 2, Description: "second", Migrate: step("second")},
+		{Version: 3, Description: "third", Migrate: step("third")},
+	}
+}
+
+func writeTestFile(t *testing.T, content string) string {
+	t.Helper()
+
+	filePath := filepath.Join(t.TempDir(), "data.json")
+
+	err := os.WriteFile(filePath, []byte(content), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return filePath
+}
+
+func TestPlanMigrations(t *testing.T) {
+	tests := []struct {
+		name      string
+		content   string
+		wantFrom  int
+		wantSteps []int
+		wantErr   error
+	}{
+		{"no version is version 0", `{"items": []}`, 0, []int{1, 2, 3}, nil},
+		{"part way", `{"version": 1}`, 1, []int{2, 3}, nil},
+		{"up to date", `{"version": 3}`, 3, []int{}, nil},
+		{"newer than the app", `{"version": 4}`, 0, nil, errUnsupportedVersion},
+		{"negative version", `{"version": -1}`, 0, nil, errInvalidVersion},
+		{"fractional version", `{"version": 1.5}`, 0, nil, errInvalidVersion},
+		{"version not a number", `{"version": "2"}`, 0, nil, errInvalidVersion},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			filePath := writeTestFile(t, test.content)
+
+			report, err := PlanMigrations(filePath, testMigrations(), nil)
+			if !errors.Is(err, test.wantErr) {
+				t.Fatalf("PlanMigrations() error = %v, want %v", err, test.wantErr)
+			}
+
+			if err != nil {
+				return
+			}
+
+			versions := []int{}
+			for _, step := range report.Steps {
+				versions = append(versions, step.Version)
+			}
+
+			if report.FromVersion != test.wantFrom || report.ToVersion != 3 || !slices.Equal(versions, test.wantSteps) {
+				t.Errorf("PlanMigrations() = %d -> %d, steps %v, want %d -> 3, steps %v",
+					report.FromVersion, report.ToVersion, versions, test.wantFrom, test.wantSteps)
+			}
+
+			// Planning never changes the file
+			content, err := os.ReadFile(filePath)
+			if err != nil || string(content) != test.content {
+				t.Errorf("PlanMigrations() changed the file to %s", content)
+			}
+		})
+	}
+}
+
+func TestReadVersionedFile(t *testing.T) {
+	filePath := writeTestFile(t, `{"version": 1, "items": ["original"], "count": 2}`)
+
+	list, err := ReadVersionedFile[testList](filePath, testMigrations(), nil)
+	if err != nil {
+		t.Fatalf("ReadVersionedFile() error = %v", err)
+	}
+
+	want := testList{Version: 3, Items: []string{"original", "second", "third"}, Count: 2}
+	if list.Version != want.Version || !slices.Equal(list.Items, want.Items) || list.Count != want.Count {
+		t.Errorf("ReadVersionedFile() = %+v, want %+v", *list, want)
+	}
+
+	// The upgraded file is written back, so reading it again runs no migrations
+	list, err = ReadVersionedFile[testList](filePath, testMigrations(), nil)
+	if err != nil || !slices.Equal(list.Items, want.Items) {
+		t.Errorf("second ReadVersionedFile() = %+v, %v, want %+v", list, err, want)
+	}
+}
+
+func TestReadVersionedFileMigrationError(t *testing.T) {
+	content := `{"version": 0}`
+	filePath := writeTestFile(t, content)
+	errMigration := errors.New("migration failed")
+
+	migrations := testMigrations()
+	migrations[1].Migrate = func(map[string]any) error { return errMigration }
+
+	_, err := ReadVersionedFile[testList](filePath, migrations, nil)
+	if !errors.Is(err, errMigration) {
+		t.Fatalf("ReadVersionedFile() error = %v, want %v", err, errMigration)
+	}
+
+	// A failed upgrade leaves the file as it was, rather than part way through
+	fileContent, err := os.ReadFile(filePath)
+	if err != nil || string(fileContent) != content {
+		t.Errorf("ReadVersionedFile() changed the file to %s", fileContent)
+	}
+}
+
+func TestLatestVersion(t *testing.T) {
+	if got := LatestVersion(nil); got != 0 {
+		t.Errorf("LatestVersion(nil) = %d, want 0", got)
+	}
+
+	if got := LatestVersion(testMigrations()); got != 3 {
+		t.Errorf("LatestVersion() = %d, want 3", got)
+	}
+}
@@ -0,0 +1,159 @@
+package geohandler
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Mean radius of the Earth, used by both the projection and haversine distances.
+const earthRadiusKilometres = 6371.0
+
+// Coordinates is a real-world location, in decimal degrees.
+type Coordinates struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+}
+
+// Projection maps real-world coordinates to grid cells. The origin is the location of grid cell (0, 0).
+type Projection struct {
+	Origin            Coordinates
+	KilometresPerUnit float64
+}
+
+var errInvalidCoordinates = errors.New("latitude must be between -90 and 90, and longitude between -180 and 180")
+
+var errInvalidPostcodeFile = errors.New("postcode file rows must be postcode, latitude, longitude")
+
+func wrapError(err error) error {
+	return fmt.Errorf("geoHandler: %w", err)
+}
+
+func (c Coordinates) Validate() error {
+	if c.Latitude < -90 || c.Latitude > 90 || c.Longitude < -180 || c.Longitude > 180 {
+		return wrapError(errInvalidCoordinates)
+	}
+
+	return nil
+}
+
+func (c Coordinates) String() string {
+	return fmt.Sprintf("%.5f, %.5f", c.Latitude, c.Longitude)
+}
+
+// ParseCoordinates parses a "latitude, longitude" pair.
+func ParseCoordinates(value string) (Coordinates, error) {
+	latitude, longitude, found := strings.Cut(value, ",")
+	if !found {
+		return Coordinates{}, wrapError(errInvalidCoordinates)
+	}
+
+	var coordinates Coordinates
+
+	var err error
+
+	coordinates.Latitude, err = strconv.ParseFloat(strings.TrimSpace(latitude), 64)
+	if err != nil {
+		return Coordinates{}, wrapError(errInvalidCoordinates)
+	}
+
+	coordinates.Longitude, err = strconv.ParseFloat(strings.TrimSpace(longitude), 64)
+	if err != nil {
+		return Coordinates{}, wrapError(errInvalidCoordinates)
+	}
+
+	return coordinates, coordinates.Validate()
+}
+
+func radians(degrees float64) float64 {
+	return degrees * math.Pi / 180
+}
+
+func degrees(radians float64) float64 {
+	return radians * 180 / math.Pi
+}
+
+// HaversineKilometres returns the great-circle distance between two locations.
+func HaversineKilometres(from Coordinates, to Coordinates) float64 {
+	latitudeDelta := radians(to.Latitude - from.Latitude)
+	longitudeDelta := radians(to.Longitude - from.Longitude)
+
+	a := math.Pow(math.Sin(latitudeDelta/2), 2) +
+		math.Cos(radians(from.Latitude))*math.Cos(radians(to.Latitude))*math.Pow(math.Sin(longitudeDelta/2), 2)
+
+	return 2 * earthRadiusKilometres * math.Asin(math.Sqrt(a))
+}
+
+// Enabled reports whether a projection has been configured.
+func (p Projection) Enabled() bool {
+	return p.KilometresPerUnit > 0
+}
+
+// ToGrid returns the grid cell containing a location, using an equirectangular projection about the origin.
+// This is accurate enough over the few hundred kilometres the grid covers.
+//
+//nolint:nonamedreturns // Named returns for clarity with same type
+func (p Projection) ToGrid(coordinates Coordinates) (x int, y int) {
+	eastKilometres := earthRadiusKilometres * radians(coordinates.Longitude-p.Origin.Longitude) *
+		math.Cos(radians(p.Origin.Latitude))
+	northKilometres := earthRadiusKilometres * radians(coordinates.Latitude-p.Origin.Latitude)
+
+	return int(math.Round(eastKilometres / p.KilometresPerUnit)), int(math.Round(northKilometres / p.KilometresPerUnit))
+}
+
+// FromGrid returns the location of a grid cell, reversing ToGrid.
+func (p Projection) FromGrid(x int, y int) Coordinates {
+	eastKilometres := float64(x) * p.KilometresPerUnit
+	northKilometres := float64(y) * p.KilometresPerUnit
+
+	return Coordinates{
+		Latitude: p.Origin.Latitude + degrees(northKilometres/earthRadiusKilometres),
+		Longitude: p.Origin.Longitude +
+			degrees(eastKilometres/(earthRadiusKilometres*math.Cos(radians(p.Origin.Latitude)))),
+	}
+}
+
+// NormalisePostcode upper-cases a postcode and removes its spaces, so differently formatted postcodes match.
+func NormalisePostcode(postcode string) string {
+	return strings.ToUpper(strings.ReplaceAll(postcode, " ", ""))
+}
+
+// LoadPostcodes reads a CSV file of postcode, latitude, longitude rows, with a header, keyed by normalised postcode.
+func LoadPostcodes(filePath string) (map[string]Coordinates, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, wrapError(err)
+	}
+	defer file.Close()
+
+	records, err := csv.NewReader(file).ReadAll()
+	if err != nil {
+		return nil, wrapError(err)
+	}
+
+	postcodes := map[string]Coordinates{}
+
+	for i, record := range records {
+		// Skip the header row
+		if i == 0 {
+			continue
+		}
+
+		if len(record) != 3 {
+			return nil, wrapError(fmt.Errorf("%w: row %d", errInvalidPostcodeFile, i+1))
+		}
+
+		coordinates, err := ParseCoordinates(record[1] + "," + record[2])
+		if err != nil {
+			return nil, wrapError(fmt.Errorf("%w: row %d", errInvalidPostcodeFile, i+1))
+		}
+
+		postcodes[NormalisePostcode(record[0])] = coordinates
+	}
+
+	return postcodes, nil
+}
@@ -0,0 +1,55 @@
+package idhandler
+
+import (
+	"crypto/rand"
+	"fmt"
+)
+
+func wrapError(err error) error {
+	return fmt.Errorf("idHandler: %w", err)
+}
+
+// Generate returns a new random (version 4) UUID, used as an immutable identifier for stored records.
+func Generate() (string, error) {
+	uuid := make([]byte, 16)
+
+	_, err := rand.Read(uuid)
+	if err != nil {
+		return "", wrapError(err)
+	}
+
+	// Set the version (4) and variant (RFC 4122) bits
+	uuid[6] = (uuid[6] & 0x0f) | 0x40
+	uuid[8] = (uuid[8] & 0x3f) | 0x80
+
+	return fmt.Sprintf("%x-%x-%x-%x-%x", uuid[0:4], uuid[4:6], uuid[6:8], uuid[8:10], uuid[10:]), nil
+}
+
+// AssignMissing gives each record of a raw JSON list an "id" if it does not already have one.
+// Used by data file migrations to add IDs to existing records.
+func AssignMissing(records any) error {
+	recordList, ok := records.([]any)
+	if !ok {
+		return nil
+	}
+
+	for _, record := range recordList {
+		fields, ok := record.(map[string]any)
+		if !ok {
+			continue
+		}
+
+		if id, ok := fields["id"].(string); ok && id != "" {
+			continue
+		}
+
+		id, err := Generate()
+		if err != nil {
+			return err
+		}
+
+		fields["id"] = id
+	}
+
+	return nil
+}
@@ -0,0 +1,330 @@
+package maphandler
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	transporthandler "work-mini-project/pkg/transportHandler"
+)
+
+// Layout of exported maps, in pixels.
+const (
+	pixelsPerUnit   = 8
+	imageMargin     = 40
+	legendHeight    = 30
+	gridLineSpacing = 10 // In grid units
+	routeWidth      = 3
+	routeOffset     = 4 // Separates routes that share a path, such as lorries and canal boats
+	customerRadius  = 5
+	depotSize       = 14
+)
+
+var errUnsupportedMapFormat = errors.New("map file must have a .svg or .png extension")
+
+//nolint:gochecknoglobals // Fixed palette, shared by the SVG and PNG renderers
+var (
+	backgroundColour  = color.RGBA{R: 255, G: 255, B: 255, A: 255}
+	gridLineColour    = color.RGBA{R: 230, G: 230, B: 230, A: 255}
+	borderColour      = color.RGBA{R: 120, G: 120, B: 120, A: 255}
+	depotColour       = color.RGBA{R: 0, G: 0, B: 0, A: 255}
+	customerColour    = color.RGBA{R: 140, G: 140, B: 140, A: 255}
+	highlightedColour = color.RGBA{R: 255, G: 127, B: 14, A: 255}
+	unknownColour     = color.RGBA{R: 148, G: 103, B: 189, A: 255}
+
+	methodColours = map[string]color.RGBA{
+		transporthandler.MethodLorry:      {R: 214, G: 39, B: 40, A: 255},
+		transporthandler.MethodCanalBoat:  {R: 31, G: 119, B: 180, A: 255},
+		transporthandler.MethodHelicopter: {R: 44, G: 160, B: 44, A: 255},
+	}
+)
+
+// MethodColour returns the colour routes of a transport method are drawn in.
+func MethodColour(method string) color.RGBA {
+	if colour, ok := methodColours[method]; ok {
+		return colour
+	}
+
+	return unknownColour
+}
+
+func hexColour(colour color.RGBA) string {
+	return fmt.Sprintf("#%02x%02x%02x", colour.R, colour.G, colour.B)
+}
+
+func (m *Map) imageSize() (int, int) {
+	spanX, spanY := m.span()
+
+	return int(spanX*pixelsPerUnit) + 2*imageMargin, int(spanY*pixelsPerUnit) + 2*imageMargin + legendHeight
+}
+
+// Convert a grid location to image pixels, with the Y axis increasing up the image.
+func (m *Map) toPixels(point transporthandler.Point) (float64, float64) {
+	return imageMargin + (point.X-float64(m.limits.MinX))*pixelsPerUnit,
+		imageMargin + (float64(m.limits.MaxY)-point.Y)*pixelsPerUnit
+}
+
+// Offset of a route from its true path, so routes sharing a path are all visible.
+func routeShift(method string) float64 {
+	index := slices.Index(transporthandler.Methods(), method)
+
+	return float64(index-1) * routeOffset
+}
+
+// Transport methods with a route on the map, in quote order.
+func (m *Map) routeMethods() []string {
+	methods := []string{}
+
+	for _, route := range m.routes {
+		if !slices.Contains(methods, route.Method) {
+			methods = append(methods, route.Method)
+		}
+	}
+
+	slices.SortStableFunc(methods, func(a, b string) int {
+		return slices.Index(transporthandler.Methods(), a) - slices.Index(transporthandler.Methods(), b)
+	})
+
+	return methods
+}
+
+// Export writes the map to an SVG or PNG file, chosen by the file extension.
+func (m *Map) Export(filePath string) error {
+	var write func(io.Writer) error
+
+	switch strings.ToLower(filepath.Ext(filePath)) {
+	case ".svg":
+		write = m.WriteSVG
+	case ".png":
+		write = m.WritePNG
+	default:
+		return wrapError(errUnsupportedMapFormat)
+	}
+
+	file, err := os.Create(filePath)
+	if err != nil {
+		return wrapError(err)
+	}
+
+	err = write(file)
+	if err != nil {
+		_ = file.Close()
+
+		return err
+	}
+
+	// Closing flushes the image to disk, so its error means the export failed
+	err = file.Close()
+	if err != nil {
+		return wrapError(err)
+	}
+
+	return nil
+}
+
+// WriteSVG writes the map as an SVG image, with routes colour-coded by transport method and a legend.
+func (m *Map) WriteSVG(writer io.Writer) error {
+	width, height := m.imageSize()
+	left, top := m.toPixels(transporthandler.Point{X: float64(m.limits.MinX), Y: float64(m.limits.MaxY)})
+	right, bottom := m.toPixels(transporthandler.Point{X: float64(m.limits.MaxX), Y: float64(m.limits.MinY)})
+
+	var svg strings.Builder
+
+	fmt.Fprintf(&svg, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" `+
+		`font-family="sans-serif" font-size="12">`+"\n", width, height, width, height)
+	fmt.Fprintf(&svg, `<rect width="100%%" height="100%%" fill="%s"/>`+"\n", hexColour(backgroundColour))
+
+	for x := m.limits.MinX; x <= m.limits.MaxX; x += gridLineSpacing {
+		pixelX, _ := m.toPixels(transporthandler.Point{X: float64(x)})
+		fmt.Fprintf(&svg, `<line x1="%g" y1="%g" x2="%g" y2="%g" stroke="%s"/>`+"\n",
+			pixelX, top, pixelX, bottom, hexColour(gridLineColour))
+		fmt.Fprintf(&svg, `<text x="%g" y="%g" text-anchor="middle">%d</text>`+"\n", pixelX, bottom+16, x)
+	}
+
+	for y := m.limits.MinY; y <= m.limits.MaxY; y += gridLineSpacing {
+		_, pixelY := m.toPixels(transporthandler.Point{Y: float64(y)})
+		fmt.Fprintf(&svg, `<line x1="%g" y1="%g" x2="%g" y2="%g" stroke="%s"/>`+"\n",
+			left, pixelY, right, pixelY, hexColour(gridLineColour))
+		fmt.Fprintf(&svg, `<text x="%g" y="%g" text-anchor="end">%d</text>`+"\n", left-6, pixelY+4, y)
+	}
+
+	fmt.Fprintf(&svg, `<rect x="%g" y="%g" width="%g" height="%g" fill="none" stroke="%s"/>`+"\n",
+		left, top, right-left, bottom-top, hexColour(borderColour))
+
+	for _, route := range m.routes {
+		shift := routeShift(route.Method)
+		points := make([]string, len(route.Points))
+
+		for i, point := range route.Points {
+			x, y := m.toPixels(point)
+			points[i] = fmt.Sprintf("%g,%g", x+shift, y+shift)
+		}
+
+		fmt.Fprintf(&svg, `<polyline points="%s" fill="none" stroke="%s" stroke-width="%d">`+
+			`<title>%s</title></polyline>`+"\n",
+			strings.Join(points, " "), hexColour(MethodColour(route.Method)), routeWidth,
+			html.EscapeString(route.Method))
+	}
+
+	for _, customer := range m.customers {
+		x, y := m.toPixels(transporthandler.Point{X: float64(customer.GridX), Y: float64(customer.GridY)})
+
+		colour := customerColour
+		if m.highlighted[customer.ID] {
+			colour = highlightedColour
+		}
+
+		fmt.Fprintf(&svg, `<circle cx="%g" cy="%g" r="%d" fill="%s"><title>%s</title></circle>`+"\n",
+			x, y, customerRadius, hexColour(colour), html.EscapeString(customer.Name))
+
+		if m.highlighted[customer.ID] {
+			fmt.Fprintf(&svg, `<text x="%g" y="%g">%s</text>`+"\n", x+8, y-8, html.EscapeString(customer.Name))
+		}
+	}
+
+	depotX, depotY := m.toPixels(m.depot)
+	fmt.Fprintf(&svg, `<rect x="%g" y="%g" width="%d" height="%d" fill="%s"><title>Depot</title></rect>`+"\n",
+		depotX-depotSize/2, depotY-depotSize/2, depotSize, depotSize, hexColour(depotColour))
+
+	// Legend, below the grid
+	legendX, legendY := float64(imageMargin), float64(height-imageMargin/2)
+
+	fmt.Fprintf(&svg, `<rect x="%g" y="%g" width="10" height="10" fill="%s"/>`+"\n",
+		legendX, legendY-10, hexColour(depotColour))
+	fmt.Fprintf(&svg, `<text x="%g" y="%g">Depot</text>`+"\n", legendX+14, legendY)
+	legendX += 70
+
+	fmt.Fprintf(&svg, `<circle cx="%g" cy="%g" r="5" fill="%s"/>`+"\n", legendX+5, legendY-5, hexColour(customerColour))
+	fmt.Fprintf(&svg, `<text x="%g" y="%g">Customer</text>`+"\n", legendX+14, legendY)
+	legendX += 90
+
+	for _, method := range m.routeMethods() {
+		fmt.Fprintf(&svg, `<line x1="%g" y1="%g" x2="%g" y2="%g" stroke="%s" stroke-width="%d"/>`+"\n",
+			legendX, legendY-5, legendX+20, legendY-5, hexColour(MethodColour(method)), routeWidth)
+		fmt.Fprintf(&svg, `<text x="%g" y="%g">%s</text>`+"\n", legendX+26, legendY, html.EscapeString(method))
+		legendX += 110
+	}
+
+	svg.WriteString("</svg>\n")
+
+	_, err := io.WriteString(writer, svg.String())
+	if err != nil {
+		return wrapError(err)
+	}
+
+	return nil
+}
+
+// WritePNG writes the map as a PNG image. Labels aren't drawn, as there is no font to draw them with,
+// so routes are identified by their colour alone.
+func (m *Map) WritePNG(writer io.Writer) error {
+	width, height := m.imageSize()
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+
+	fillRect(img, 0, 0, float64(width), float64(height), backgroundColour)
+
+	left, top := m.toPixels(transporthandler.Point{X: float64(m.limits.MinX), Y: float64(m.limits.MaxY)})
+	right, bottom := m.toPixels(transporthandler.Point{X: float64(m.limits.MaxX), Y: float64(m.limits.MinY)})
+
+	for x := m.limits.MinX; x <= m.limits.MaxX; x += gridLineSpacing {
+		pixelX, _ := m.toPixels(transporthandler.Point{X: float64(x)})
+		drawLine(img, pixelX, top, pixelX, bottom, 1, gridLineColour)
+	}
+
+	for y := m.limits.MinY; y <= m.limits.MaxY; y += gridLineSpacing {
+		_, pixelY := m.toPixels(transporthandler.Point{Y: float64(y)})
+		drawLine(img, left, pixelY, right, pixelY, 1, gridLineColour)
+	}
+
+	drawLine(img, left, top, right, top, 1, borderColour)
+	drawLine(img, right, top, right, bottom, 1, borderColour)
+	drawLine(img, right, bottom, left, bottom, 1, borderColour)
+	drawLine(img, left, bottom, left, top, 1, borderColour)
+
+	for _, route := range m.routes {
+		shift := routeShift(route.Method)
+
+		for i := 1; i < len(route.Points); i++ {
+			startX, startY := m.toPixels(route.Points[i-1])
+			endX, endY := m.toPixels(route.Points[i])
+			drawLine(img, startX+shift, startY+shift, endX+shift, endY+shift, routeWidth, MethodColour(route.Method))
+		}
+	}
+
+	for _, customer := range m.customers {
+		x, y := m.toPixels(transporthandler.Point{X: float64(customer.GridX), Y: float64(customer.GridY)})
+
+		colour := customerColour
+		if m.highlighted[customer.ID] {
+			colour = highlightedColour
+		}
+
+		fillCircle(img, x, y, customerRadius, colour)
+	}
+
+	depotX, depotY := m.toPixels(m.depot)
+	fillRect(img, depotX-depotSize/2, depotY-depotSize/2, depotSize, depotSize, depotColour)
+
+	// Legend swatches, below the grid, in the same order as the SVG legend
+	legendX, legendY := float64(imageMargin), float64(height-imageMargin/2)
+
+	fillRect(img, legendX, legendY-10, 10, 10, depotColour)
+	fillCircle(img, legendX+25, legendY-5, 5, customerColour)
+
+	for i, method := range m.routeMethods() {
+		startX := legendX + 50 + float64(i*40)
+		drawLine(img, startX, legendY-5, startX+20, legendY-5, routeWidth, MethodColour(method))
+	}
+
+	err := png.Encode(writer, img)
+	if err != nil {
+		return wrapError(err)
+	}
+
+	return nil
+}
+
+func fillRect(img *image.RGBA, x float64, y float64, width float64, height float64, colour color.RGBA) {
+	for pixelY := int(math.Round(y)); pixelY < int(math.Round(y+height)); pixelY++ {
+		for pixelX := int(math.Round(x)); pixelX < int(math.Round(x+width)); pixelX++ {
+			img.SetRGBA(pixelX, pixelY, colour)
+		}
+	}
+}
+
+func fillCircle(img *image.RGBA, centreX float64, centreY float64, radius float64, colour color.RGBA) {
+	for pixelY := int(centreY - radius); pixelY <= int(centreY+radius); pixelY++ {
+		for pixelX := int(centreX - radius); pixelX <= int(centreX+radius); pixelX++ {
+			if math.Hypot(float64(pixelX)-centreX, float64(pixelY)-centreY) <= radius {
+				img.SetRGBA(pixelX, pixelY, colour)
+			}
+		}
+	}
+}
+
+// Draw a line of the given width, by stamping a square of that width at every pixel along it.
+func drawLine(
+	img *image.RGBA,
+	startX float64, startY float64,
+	endX float64, endY float64,
+	width float64,
+	colour color.RGBA,
+) {
+	steps := int(math.Max(math.Abs(endX-startX), math.Abs(endY-startY))) + 1
+
+	for step := 0; step <= steps; step++ {
+		fraction := float64(step) / float64(steps)
+		x := startX + fraction*(endX-startX)
+		y := startY + fraction*(endY-startY)
+
+		fillRect(img, x-width/2, y-width/2, math.Max(width, 1), math.Max(width, 1), colour)
+	}
+}
@@ -0,0 +1,185 @@
+package maphandler
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"work-mini-project/pkg/configuration"
+	customerhandler "work-mini-project/pkg/customerHandler"
+	transporthandler "work-mini-project/pkg/transportHandler"
+)
+
+// Smallest map drawn, in characters, however narrow the terminal is.
+const (
+	minTextColumns = 20
+	minTextRows    = 5
+)
+
+// Terminal characters are roughly twice as tall as they are wide,
+// so rows are halved to keep the grid in proportion.
+const textCellAspect = 2
+
+// Route is the path a transport method takes from the depot to a customer.
+type Route struct {
+	CustomerID string
+	Method     string
+	Points     []transporthandler.Point
+}
+
+// Map draws the grid, the depot, customers and any routes to customers.
+type Map struct {
+	limits    configuration.GridLimitsConfig
+	depot     transporthandler.Point
+	customers []customerhandler.Customer
+	routes    []Route
+
+	highlighted map[string]bool // IDs of customers marked out from the rest
+}
+
+// Characters used to draw a text map.
+type charset struct {
+	depot, customer, destination, route rune
+	horizontal, vertical                rune
+	topLeft, topRight                   rune
+	bottomLeft, bottomRight             rune
+}
+
+var unicodeCharset = charset{
+	depot: '■', customer: '●', destination: '◉', route: '·',
+	horizontal: '─', vertical: '│',
+	topLeft: '┌', topRight: '┐',
+	bottomLeft: '└', bottomRight: '┘',
+}
+
+var asciiCharset = charset{
+	depot: 'D', customer: 'o', destination: '@', route: '.',
+	horizontal: '-', vertical: '|',
+	topLeft: '+', topRight: '+',
+	bottomLeft: '+', bottomRight: '+',
+}
+
+func wrapError(err error) error {
+	return fmt.Errorf("mapHandler: %w", err)
+}
+
+func New(config *configuration.Config, customers []customerhandler.Customer) *Map {
+	return &Map{
+		limits:    config.GridLimits,
+		depot:     transporthandler.Point{X: float64(config.Company.GridX), Y: float64(config.Company.GridY)},
+		customers: customers,
+		routes:    []Route{},
+
+		highlighted: map[string]bool{},
+	}
+}
+
+// Highlight marks a customer out from the rest, and names it in the legend.
+func (m *Map) Highlight(customer customerhandler.Customer) {
+	m.highlighted[customer.ID] = true
+}
+
+// AddRoute draws the route of a quoted trip to a customer on the map, highlighting the customer.
+func (m *Map) AddRoute(customer customerhandler.Customer, trip *transporthandler.TripDetails) {
+	m.routes = append(m.routes, Route{CustomerID: customer.ID, Method: trip.Method, Points: trip.Route})
+	m.Highlight(customer)
+}
+
+func (m *Map) span() (float64, float64) {
+	spanX := math.Max(float64(m.limits.MaxX-m.limits.MinX), 1)
+	spanY := math.Max(float64(m.limits.MaxY-m.limits.MinY), 1)
+
+	return spanX, spanY
+}
+
+// RenderText draws the map to fit within the given terminal width, with Unicode or plain ASCII characters.
+func (m *Map) RenderText(width int, ascii bool) string {
+	chars := unicodeCharset
+	if ascii {
+		chars = asciiCharset
+	}
+
+	// Leave room for the border
+	columns := max(width-2, minTextColumns)
+	spanX, spanY := m.span()
+	rows := max(int(math.Round(float64(columns)*spanY/spanX/textCellAspect)), minTextRows)
+
+	grid := make([][]rune, rows)
+	for row := range grid {
+		grid[row] = []rune(strings.Repeat(" ", columns))
+	}
+
+	// Convert a grid location to a cell, with the Y axis increasing up the map
+	cell := func(point transporthandler.Point) (int, int) {
+		column := int(math.Round((point.X - float64(m.limits.MinX)) / spanX * float64(columns-1)))
+		row := int(math.Round((float64(m.limits.MaxY) - point.Y) / spanY * float64(rows-1)))
+
+		return min(max(row, 0), rows-1), min(max(column, 0), columns-1)
+	}
+
+	for _, route := range m.routes {
+		for i := 1; i < len(route.Points); i++ {
+			startRow, startColumn := cell(route.Points[i-1])
+			endRow, endColumn := cell(route.Points[i])
+
+			// Step finely enough along the segment to mark every cell it passes through
+			steps := max(abs(endRow-startRow), abs(endColumn-startColumn), 1) * 2
+
+			for step := 0; step <= steps; step++ {
+				fraction := float64(step) / float64(steps)
+				row := startRow + int(math.Round(fraction*float64(endRow-startRow)))
+				column := startColumn + int(math.Round(fraction*float64(endColumn-startColumn)))
+				grid[row][column] = chars.route
+			}
+		}
+	}
+
+	legend := []string{
+		fmt.Sprintf("%c Depot (%g, %g)", chars.depot, m.depot.X, m.depot.Y),
+		fmt.Sprintf("%c Customer", chars.customer),
+	}
+
+	for _, customer := range m.customers {
+		row, column := cell(transporthandler.Point{X: float64(customer.GridX), Y: float64(customer.GridY)})
+
+		if m.highlighted[customer.ID] {
+			grid[row][column] = chars.destination
+			legend = append(legend, fmt.Sprintf("%c %s", chars.destination, customer.Name))
+		} else if grid[row][column] != chars.destination {
+			grid[row][column] = chars.customer
+		}
+	}
+
+	for _, route := range m.routes {
+		legend = append(legend, fmt.Sprintf("%c %s route", chars.route, route.Method))
+	}
+
+	depotRow, depotColumn := cell(m.depot)
+	grid[depotRow][depotColumn] = chars.depot
+
+	var output strings.Builder
+
+	output.WriteString(fmt.Sprintf("(%d, %d)\n", m.limits.MinX, m.limits.MaxY))
+	output.WriteString(string(chars.topLeft) + strings.Repeat(string(chars.horizontal), columns) +
+		string(chars.topRight) + "\n")
+
+	for _, row := range grid {
+		output.WriteString(string(chars.vertical) + string(row) + string(chars.vertical) + "\n")
+	}
+
+	output.WriteString(string(chars.bottomLeft) + strings.Repeat(string(chars.horizontal), columns) +
+		string(chars.bottomRight) + "\n")
+
+	bottomRightLabel := fmt.Sprintf("(%d, %d)", m.limits.MaxX, m.limits.MinY)
+	output.WriteString(strings.Repeat(" ", max(columns+2-len(bottomRightLabel), 0)) + bottomRightLabel + "\n\n")
+	output.WriteString(strings.Join(legend, "   "))
+
+	return output.String()
+}
+
+func abs(value int) int {
+	if value < 0 {
+		return -value
+	}
+
+	return value
+}
@@ -0,0 +1,497 @@
+//nolint:mnd // QR code layout is defined by the specification, ignore magic numbers in this file.
+package qrhandler
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// QR codes are encoded in byte mode at error correction level L, which is plenty for codes shown on a screen.
+// Versions 1-10 are supported, holding up to 271 bytes.
+const maxVersion = 10
+
+// Error correction layout of each version at level L.
+type versionLayout struct {
+	eccPerBlock int
+	blocks      []int // Data codewords in each block
+	alignment   []int // Alignment pattern centre coordinates
+}
+
+//nolint:gochecknoglobals // Fixed tables from the QR code specification
+var layouts = [maxVersion + 1]versionLayout{
+	{},
+	{7, []int{19}, nil},
+	{10, []int{34}, []int{6, 18}},
+	{15, []int{55}, []int{6, 22}},
+	{20, []int{80}, []int{6, 26}},
+	{26, []int{108}, []int{6, 30}},
+	{18, []int{68, 68}, []int{6, 34}},
+	{20, []int{78, 78}, []int{6, 22, 38}},
+	{24, []int{97, 97}, []int{6, 24, 42}},
+	{30, []int{116, 116}, []int{6, 26, 46}},
+	{18, []int{68, 68, 69, 69}, []int{6, 28, 50}},
+}
+
+// Format information bits identifying error correction level L.
+const eccLevelLBits = 1
+
+var errTooLong = errors.New("text is too long to encode as a QR code")
+
+func wrapError(err error) error {
+	return fmt.Errorf("qrHandler: %w", err)
+}
+
+// Code is a QR code symbol, true for dark modules.
+type Code struct {
+	size       int
+	modules    [][]bool
+	isFunction [][]bool
+}
+
+func newCode(version int) *Code {
+	size := version*4 + 17
+
+	code := &Code{size: size, modules: make([][]bool, size), isFunction: make([][]bool, size)}
+	for y := range size {
+		code.modules[y] = make([]bool, size)
+		code.isFunction[y] = make([]bool, size)
+	}
+
+	return code
+}
+
+func (code *Code) Size() int {
+	return code.size
+}
+
+// Dark reports whether the module at column x, row y is dark.
+func (code *Code) Dark(x int, y int) bool {
+	return code.modules[y][x]
+}
+
+func (code *Code) setFunction(x int, y int, dark bool) {
+	code.modules[y][x] = dark
+	code.isFunction[y][x] = true
+}
+
+func dataCapacity(version int) int {
+	total := 0
+	for _, block := range layouts[version].blocks {
+		total += block
+	}
+
+	return total
+}
+
+// Encode creates the smallest QR code holding the text.
+func Encode(text string) (*Code, error) {
+	data := []byte(text)
+
+	version := 1
+	for ; version <= maxVersion; version++ {
+		countBits := 8
+		if version >= 10 {
+			countBits = 16
+		}
+
+		if 4+countBits+len(data)*8 <= dataCapacity(version)*8 {
+			break
+		}
+	}
+
+	if version > maxVersion {
+		return nil, wrapError(errTooLong)
+	}
+
+	codewords := addErrorCorrection(encodeData(data, version), version)
+
+	code := newCode(version)
+	code.drawFunctionPatterns(version)
+	code.drawCodewords(codewords)
+
+	// Use the mask giving the lowest penalty, so the code is easiest to scan
+	bestMask, bestPenalty := 0, -1
+
+	for mask := range 8 {
+		code.applyMask(mask)
+		code.drawFormatBits(mask)
+
+		if penalty := code.penalty(); bestPenalty == -1 || penalty < bestPenalty {
+			bestMask, bestPenalty = mask, penalty
+		}
+
+		// Masks are their own inverse
+		code.applyMask(mask)
+	}
+
+	code.applyMask(bestMask)
+	code.drawFormatBits(bestMask)
+
+	return code, nil
+}
+
+// Encode the data in byte mode, padded to the data capacity of the version.
+func encodeData(data []byte, version int) []byte {
+	bits := []bool{}
+	appendBits := func(value int, length int) {
+		for i := length - 1; i >= 0; i-- {
+			bits = append(bits, (value>>i)&1 == 1)
+		}
+	}
+
+	countBits := 8
+	if version >= 10 {
+		countBits = 16
+	}
+
+	appendBits(0b0100, 4)
+	appendBits(len(data), countBits)
+
+	for _, b := range data {
+		appendBits(int(b), 8)
+	}
+
+	capacity := dataCapacity(version) * 8
+
+	// Terminator, then pad to a whole byte
+	appendBits(0, min(4, capacity-len(bits)))
+	appendBits(0, (8-len(bits)%8)%8)
+
+	// Alternating pad bytes fill the remaining capacity
+	for pad := 0xEC; len(bits) < capacity; pad ^= 0xEC ^ 0x11 {
+		appendBits(pad, 8)
+	}
+
+	codewords := make([]byte, len(bits)/8)
+
+	for i, bit := range bits {
+		if bit {
+			codewords[i/8] |= 1 << (7 - i%8)
+		}
+	}
+
+	return codewords
+}
+
+// Split the data into blocks, add error correction codewords to each, and interleave the blocks.
+func addErrorCorrection(data []byte, version int) []byte {
+	layout := layouts[version]
+	generator := rsGenerator(layout.eccPerBlock)
+
+	dataBlocks := [][]byte{}
+	eccBlocks := [][]byte{}
+	offset := 0
+
+	for _, length := range layout.blocks {
+		block := data[offset : offset+length]
+		offset += length
+
+		dataBlocks = append(dataBlocks, block)
+		eccBlocks = append(eccBlocks, rsRemainder(block, generator))
+	}
+
+	result := []byte{}
+
+	for i := range layout.blocks[len(layout.blocks)-1] {
+		for _, block := range dataBlocks {
+			if i < len(block) {
+				result = append(result, block[i])
+			}
+		}
+	}
+
+	for i := range layout.eccPerBlock {
+		for _, block := range eccBlocks {
+			result = append(result, block[i])
+		}
+	}
+
+	return result
+}
+
+func (code *Code) drawFunctionPatterns(version int) {
+	// Timing patterns
+	for i := range code.size {
+		code.setFunction(6, i, i%2 == 0)
+		code.setFunction(i, 6, i%2 == 0)
+	}
+
+	// Finder patterns, with their separators
+	for _, corner := range [][2]int{{3, 3}, {code.size - 4, 3}, {3, code.size - 4}} {
+		for dy := -4; dy <= 4; dy++ {
+			for dx := -4; dx <= 4; dx++ {
+				x, y := corner[0]+dx, corner[1]+dy
+				if x < 0 || x >= code.size || y < 0 || y >= code.size {
+					continue
+				}
+
+				distance := max(abs(dx), abs(dy))
+				code.setFunction(x, y, distance != 2 && distance != 4)
+			}
+		}
+	}
+
+	// Alignment patterns, except where they would overlap the finder patterns
+	positions := layouts[version].alignment
+	for i, y := range positions {
+		for j, x := range positions {
+			if (i == 0 && j == 0) || (i == 0 && j == len(positions)-1) || (i == len(positions)-1 && j == 0) {
+				continue
+			}
+
+			for dy := -2; dy <= 2; dy++ {
+				for dx := -2; dx <= 2; dx++ {
+					code.setFunction(x+dx, y+dy, max(abs(dx), abs(dy)) != 1)
+				}
+			}
+		}
+	}
+
+	// Reserve the format areas, drawn once the mask is chosen
+	code.drawFormatBits(0)
+
+	if version >= 7 {
+		code.drawVersionBits(version)
+	}
+}
+
+func (code *Code) drawFormatBits(mask int) {
+	data := eccLevelLBits<<3 | mask
+	remainder := data
+
+	for range 10 {
+		remainder = (remainder << 1) ^ ((remainder >> 9) * 0x537)
+	}
+
+	bits := (data<<10 | remainder) ^ 0x5412
+	bit := func(i int) bool { return (bits>>i)&1 == 1 }
+
+	// First copy, around the top left finder
+	for i := range 6 {
+		code.setFunction(8, i, bit(i))
+	}
+
+	code.setFunction(8, 7, bit(6))
+	code.setFunction(8, 8, bit(7))
+	code.setFunction(7, 8, bit(8))
+
+	for i := 9; i < 15; i++ {
+		code.setFunction(14-i, 8, bit(i))
+	}
+
+	// Second copy, split between the other two finders
+	for i := range 8 {
+		code.setFunction(code.size-1-i, 8, bit(i))
+	}
+
+	for i := 8; i < 15; i++ {
+		code.setFunction(8, code.size-15+i, bit(i))
+	}
+
+	// Always dark
+	code.setFunction(8, code.size-8, true)
+}
+
+func (code *Code) drawVersionBits(version int) {
+	remainder := version
+
+	for range 12 {
+		remainder = (remainder << 1) ^ ((remainder >> 11) * 0x1F25)
+	}
+
+	bits := version<<12 | remainder
+
+	for i := range 18 {
+		dark := (bits>>i)&1 == 1
+		a, b := code.size-11+i%3, i/3
+		code.setFunction(a, b, dark)
+		code.setFunction(b, a, dark)
+	}
+}
+
+// Place the codewords in the zigzag pattern, two columns at a time from the bottom right.
+func (code *Code) drawCodewords(codewords []byte) {
+	i := 0
+
+	for right := code.size - 1; right >= 1; right -= 2 {
+		// Skip the vertical timing pattern
+		if right == 6 {
+			right = 5
+		}
+
+		for vertical := range code.size {
+			for j := range 2 {
+				x := right - j
+				y := vertical
+
+				if (right+1)&2 == 0 {
+					y = code.size - 1 - vertical
+				}
+
+				if !code.isFunction[y][x] && i < len(codewords)*8 {
+					code.modules[y][x] = (codewords[i/8]>>(7-i%8))&1 == 1
+					i++
+				}
+			}
+		}
+	}
+}
+
+func (code *Code) applyMask(mask int) {
+	for y := range code.size {
+		for x := range code.size {
+			if code.isFunction[y][x] {
+				continue
+			}
+
+			var invert bool
+
+			switch mask {
+			case 0:
+				invert = (x+y)%2 == 0
+			case 1:
+				invert = y%2 == 0
+			case 2:
+				invert = x%3 == 0
+			case 3:
+				invert = (x+y)%3 == 0
+			case 4:
+				invert = (x/3+y/2)%2 == 0
+			case 5:
+				invert = x*y%2+x*y%3 == 0
+			case 6:
+				invert = (x*y%2+x*y%3)%2 == 0
+			default:
+				invert = ((x+y)%2+x*y%3)%2 == 0
+			}
+
+			code.modules[y][x] = code.modules[y][x] != invert
+		}
+	}
+}
+
+// Score how hard the code is to scan, using the penalty rules from the specification. Lower is better.
+func (code *Code) penalty() int {
+	penalty := 0
+	dark := 0
+
+	finderLike := [][]bool{
+		{true, false, true, true, true, false, true, false, false, false, false},
+		{false, false, false, false, true, false, true, true, true, false, true},
+	}
+
+	for a := range code.size {
+		for _, horizontal := range []bool{true, false} {
+			line := make([]bool, code.size)
+			for b := range code.size {
+				if horizontal {
+					line[b] = code.modules[a][b]
+				} else {
+					line[b] = code.modules[b][a]
+				}
+			}
+
+			// Runs of five or more modules of the same colour
+			run := 1
+			for b := 1; b <= code.size; b++ {
+				if b < code.size && line[b] == line[b-1] {
+					run++
+
+					continue
+				}
+
+				if run >= 5 {
+					penalty += run - 2
+				}
+
+				run = 1
+			}
+
+			// Patterns that look like finder patterns
+			for b := 0; b+11 <= code.size; b++ {
+				for _, pattern := range finderLike {
+					if slicesEqual(line[b:b+11], pattern) {
+						penalty += 40
+					}
+				}
+			}
+		}
+
+		for b := range code.size {
+			if code.modules[a][b] {
+				dark++
+			}
+
+			// 2x2 blocks of the same colour
+			if a+1 < code.size && b+1 < code.size {
+				colour := code.modules[a][b]
+				if code.modules[a][b+1] == colour && code.modules[a+1][b] == colour && code.modules[a+1][b+1] == colour {
+					penalty += 3
+				}
+			}
+		}
+	}
+
+	// Imbalance between dark and light modules
+	percentDark := dark * 100 / (code.size * code.size)
+	penalty += abs(percentDark-50) / 5 * 10
+
+	return penalty
+}
+
+// Width of the light border around a code, in modules, as required by the specification.
+const quietZone = 4
+
+// RenderText draws the code with Unicode half blocks, two rows of modules per line of text.
+// Light modules are drawn as filled blocks, so the code reads correctly on a dark terminal background.
+func (code *Code) RenderText() string {
+	light := func(x int, y int) bool {
+		if x < 0 || y < 0 || x >= code.size || y >= code.size {
+			return true
+		}
+
+		return !code.modules[y][x]
+	}
+
+	var output strings.Builder
+
+	for y := -quietZone; y < code.size+quietZone; y += 2 {
+		for x := -quietZone; x < code.size+quietZone; x++ {
+			top, bottom := light(x, y), light(x, y+1)
+
+			switch {
+			case top && bottom:
+				output.WriteRune('█')
+			case top:
+				output.WriteRune('▀')
+			case bottom:
+				output.WriteRune('▄')
+			default:
+				output.WriteRune(' ')
+			}
+		}
+
+		output.WriteRune('\n')
+	}
+
+	return output.String()
+}
+
+func abs(value int) int {
+	if value < 0 {
+		return -value
+	}
+
+	return value
+}
+
+func slicesEqual(a []bool, b []bool) bool {
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
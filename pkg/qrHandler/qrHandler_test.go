@@ -0,0 +1,325 @@
+package qrhandler
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestRSRemainder(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want []byte
+	}{
+		{
+			// "01234567" at version 1-M, from the worked example in ISO/IEC 18004
+			name: "numeric 01234567",
+			data: []byte{0x10, 0x20, 0x0C, 0x56, 0x61, 0x80, 0xEC, 0x11, 0xEC, 0x11, 0xEC, 0x11, 0xEC, 0x11, 0xEC, 0x11},
+			want: []byte{0xA5, 0x24, 0xD4, 0xC1, 0xED, 0x36, 0xC7, 0x87, 0x2C, 0x55},
+		},
+		{
+			name: "alphanumeric HELLO WORLD",
+			data: []byte{0x20, 0x5B, 0x0B, 0x78, 0xD1, 0x72, 0xDC, 0x4D, 0x43, 0x40, 0xEC, 0x11, 0xEC, 0x11, 0xEC, 0x11},
+			want: []byte{0xC4, 0x23, 0x27, 0x77, 0xEB, 0xD7, 0xE7, 0xE2, 0x5D, 0x17},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := rsRemainder(test.data, rsGenerator(len(test.want)))
+			if !bytes.Equal(got, test.want) {
+				t.Errorf("rsRemainder() = % X, want % X", got, test.want)
+			}
+		})
+	}
+}
+
+func TestEncodeKnownOutput(t *testing.T) {
+	// Checked by scanning with an independent decoder
+	want := []string{
+		"#######.#...#.#######",
+		"#.....#.##.#..#.....#",
+		"#.###.#.#####.#.###.#",
+		"#.###.#.#.#.#.#.###.#",
+		"#.###.#..####.#.###.#",
+		"#.....#.#.#.#.#.....#",
+		"#######.#.#.#.#######",
+		".....................",
+		"##..###.....#..#.####",
+		"...#...#..#.#...#..#.",
+		"..#####..#..###.#..#.",
+		"##.##..#..####.#...#.",
+		"..##.####.##.#.......",
+		"........##..##.###.##",
+		"#######....#..#.####.",
+		"#.....#.##.#.#.##....",
+		"#.###.#.######.......",
+		"#.###.#...##..##...##",
+		"#.###.#..##..#..#....",
+		"#.....#.#.#.##.###...",
+		"#######.#...##......#",
+	}
+
+	code, err := Encode("Mini Project")
+	if err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+
+	if code.Size() != len(want) {
+		t.Fatalf("Size() = %d, want %d", code.Size(), len(want))
+	}
+
+	for y, row := range want {
+		var got strings.Builder
+
+		for x := range code.Size() {
+			if code.Dark(x, y) {
+				got.WriteByte('#')
+			} else {
+				got.WriteByte('.')
+			}
+		}
+
+		if got.String() != row {
+			t.Errorf("row %d = %s, want %s", y, got.String(), row)
+		}
+	}
+}
+
+// Format information for error correction level L with each mask, from the specification.
+var formatBitsL = [8]int{
+	0b111011111000100, 0b111001011110011, 0b111110110101010, 0b111100010011101,
+	0b110011000101111, 0b110001100011000, 0b110110001000001, 0b110100101110110,
+}
+
+// Version information for versions 7-10, from the specification.
+var versionBits = map[int]int{7: 0x07C94, 8: 0x085BC, 9: 0x09A99, 10: 0x0A4D3}
+
+// Alignment pattern centres for versions 2-10, from the specification.
+var alignmentCentres = [][]int{nil, nil, {6, 18}, {6, 22}, {6, 26}, {6, 30}, {6, 34},
+	{6, 22, 38}, {6, 24, 42}, {6, 26, 46}, {6, 28, 50}}
+
+// Check the finder, timing and alignment patterns are drawn where the specification puts them.
+func checkFunctionPatterns(t *testing.T, code *Code, version int) {
+	t.Helper()
+
+	// Finder and alignment patterns are concentric squares, coloured by distance from their centre
+	square := func(centreX int, centreY int, radius int, dark func(distance int) bool) {
+		for y := centreY - radius; y <= centreY+radius; y++ {
+			for x := centreX - radius; x <= centreX+radius; x++ {
+				if code.Dark(x, y) != dark(max(abs(x-centreX), abs(y-centreY))) {
+					t.Errorf("version %d: pattern centred at %d, %d is wrong at %d, %d", version, centreX, centreY, x, y)
+
+					return
+				}
+			}
+		}
+	}
+
+	for _, centre := range [][2]int{{3, 3}, {code.size - 4, 3}, {3, code.size - 4}} {
+		square(centre[0], centre[1], 3, func(distance int) bool { return distance != 2 })
+	}
+
+	for i := 8; i < code.size-8; i++ {
+		if code.Dark(i, 6) != (i%2 == 0) || code.Dark(6, i) != (i%2 == 0) {
+			t.Errorf("version %d: timing pattern is wrong at %d", version, i)
+		}
+	}
+
+	centres := alignmentCentres[version]
+	last := len(centres) - 1
+
+	for i, y := range centres {
+		for j, x := range centres {
+			// Alignment patterns aren't drawn over the finders
+			if (i == 0 && j == 0) || (i == 0 && j == last) || (i == last && j == 0) {
+				continue
+			}
+
+			square(x, y, 2, func(distance int) bool { return distance != 1 })
+		}
+	}
+}
+
+func readFormatBits(code *Code) (int, int) {
+	first, second := 0, 0
+	set := func(value *int, i int, x int, y int) {
+		if code.Dark(x, y) {
+			*value |= 1 << i
+		}
+	}
+
+	for i := range 6 {
+		set(&first, i, 8, i)
+	}
+
+	set(&first, 6, 8, 7)
+	set(&first, 7, 8, 8)
+	set(&first, 8, 7, 8)
+
+	for i := 9; i < 15; i++ {
+		set(&first, i, 14-i, 8)
+	}
+
+	for i := range 8 {
+		set(&second, i, code.size-1-i, 8)
+	}
+
+	for i := 8; i < 15; i++ {
+		set(&second, i, 8, code.size-15+i)
+	}
+
+	return first, second
+}
+
+// Read the codewords back from the zigzag placement, after removing the mask.
+func readCodewords(code *Code, mask int) []byte {
+	code.applyMask(mask)
+	defer code.applyMask(mask)
+
+	codewords := []byte{}
+	i := 0
+
+	for right := code.size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+
+		for vertical := range code.size {
+			for j := range 2 {
+				x, y := right-j, vertical
+				if (right+1)&2 == 0 {
+					y = code.size - 1 - vertical
+				}
+
+				if code.isFunction[y][x] {
+					continue
+				}
+
+				if i%8 == 0 {
+					codewords = append(codewords, 0)
+				}
+
+				if code.modules[y][x] {
+					codewords[i/8] |= 1 << (7 - i%8)
+				}
+
+				i++
+			}
+		}
+	}
+
+	return codewords
+}
+
+// Encode text filling each version to capacity, then decode it again and check every part of the symbol.
+func TestEncodeRoundTrip(t *testing.T) {
+	// Byte mode capacities at level L, from the specification
+	capacities := []int{0, 17, 32, 53, 78, 106, 134, 154, 192, 230, 271}
+
+	for version := 1; version <= maxVersion; version++ {
+		text := strings.Repeat("0123456789abcdefghijklmnopqrstuvwxyz", 8)[:capacities[version]]
+
+		code, err := Encode(text)
+		if err != nil {
+			t.Fatalf("version %d: Encode() error = %v", version, err)
+		}
+
+		if code.Size() != version*4+17 {
+			t.Fatalf("version %d: Size() = %d, want %d", version, code.Size(), version*4+17)
+		}
+
+		checkFunctionPatterns(t, code, version)
+
+		first, second := readFormatBits(code)
+		if first != second {
+			t.Errorf("version %d: format copies differ, %015b and %015b", version, first, second)
+		}
+
+		mask := -1
+
+		for candidate, bits := range formatBitsL {
+			if bits == first {
+				mask = candidate
+			}
+		}
+
+		if mask == -1 {
+			t.Fatalf("version %d: format bits %015b are not level L with any mask", version, first)
+		}
+
+		if want, found := versionBits[version]; found {
+			for i := range 18 {
+				a, b := code.size-11+i%3, i/3
+				if bit := (want>>i)&1 == 1; code.Dark(a, b) != bit || code.Dark(b, a) != bit {
+					t.Errorf("version %d: version bit %d is not %t", version, i, bit)
+				}
+			}
+		}
+
+		layout := layouts[version]
+		codewords := readCodewords(code, mask)
+
+		// De-interleave the data and error correction blocks
+		data := make([][]byte, len(layout.blocks))
+		ecc := make([][]byte, len(layout.blocks))
+		offset := 0
+
+		for i := range layout.blocks[len(layout.blocks)-1] {
+			for block, length := range layout.blocks {
+				if i < length {
+					data[block] = append(data[block], codewords[offset])
+					offset++
+				}
+			}
+		}
+
+		for range layout.eccPerBlock {
+			for block := range layout.blocks {
+				ecc[block] = append(ecc[block], codewords[offset])
+				offset++
+			}
+		}
+
+		for block := range layout.blocks {
+			if want := rsRemainder(data[block], rsGenerator(layout.eccPerBlock)); !bytes.Equal(ecc[block], want) {
+				t.Errorf("version %d: block %d error correction doesn't match its data", version, block)
+			}
+		}
+
+		decoded := bytes.Join(data, nil)
+
+		// Byte mode indicator and character count, then the text
+		header := 2
+		if version >= 10 {
+			header = 3
+		}
+
+		length := int(decoded[0]&0x0F)<<4 | int(decoded[1]>>4)
+		if version >= 10 {
+			length = int(decoded[0]&0x0F)<<12 | int(decoded[1])<<4 | int(decoded[2]>>4)
+		}
+
+		if decoded[0]>>4 != 0b0100 || length != len(text) {
+			t.Fatalf("version %d: header is mode %04b, length %d", version, decoded[0]>>4, length)
+		}
+
+		payload := make([]byte, length)
+		for i := range payload {
+			payload[i] = decoded[header-1+i]<<4 | decoded[header+i]>>4
+		}
+
+		if string(payload) != text {
+			t.Errorf("version %d: decoded %q, want %q", version, payload, text)
+		}
+	}
+}
+
+func TestEncodeTooLong(t *testing.T) {
+	_, err := Encode(strings.Repeat("a", 272))
+	if !errors.Is(err, errTooLong) {
+		t.Errorf("Encode() error = %v, want %v", err, errTooLong)
+	}
+}
@@ -0,0 +1,55 @@
+package qrhandler
+
+// Reed-Solomon error correction over GF(256), with the QR code primitive polynomial x^8 + x^4 + x^3 + x^2 + 1.
+const gfPrimitive = 0x11D
+
+// Multiply two elements of GF(256).
+func gfMultiply(a byte, b byte) byte {
+	result := 0
+	x, y := int(a), int(b)
+
+	for i := 7; i >= 0; i-- {
+		result = (result << 1) ^ ((result >> 7) * gfPrimitive)
+		result ^= ((y >> i) & 1) * x
+	}
+
+	return byte(result)
+}
+
+// Generator polynomial of the given degree, highest power first, with the leading 1 omitted.
+func rsGenerator(degree int) []byte {
+	generator := make([]byte, degree)
+	generator[degree-1] = 1
+
+	// Multiply by (x - r^i) for i = 0..degree-1, where r = 2
+	root := byte(1)
+
+	for range degree {
+		for j := range generator {
+			generator[j] = gfMultiply(generator[j], root)
+			if j+1 < len(generator) {
+				generator[j] ^= generator[j+1]
+			}
+		}
+
+		root = gfMultiply(root, 2)
+	}
+
+	return generator
+}
+
+// Error correction codewords for a block of data.
+func rsRemainder(data []byte, generator []byte) []byte {
+	remainder := make([]byte, len(generator))
+
+	for _, b := range data {
+		factor := b ^ remainder[0]
+		remainder = append(remainder[1:], 0)
+
+		for i, coefficient := range generator {
+			remainder[i] ^= gfMultiply(coefficient, factor)
+		}
+	}
+
+	return remainder
+}
@@ -6,17 +6,38 @@ import (
 	"time"
 	"work-mini-project/pkg/configuration"
 	customerhandler "work-mini-project/pkg/customerHandler"
+	geohandler "work-mini-project/pkg/geoHandler"
 )
 
 type TransportHandler struct {
 	config *configuration.Config
 }
 
+// Transport method names, also used as keys for customer rate cards.
+const (
+	MethodLorry      = "Lorry"
+	MethodCanalBoat  = "Canal boat"
+	MethodHelicopter = "Helicopter"
+)
+
+// Methods lists every transport method, in the order they are quoted.
+func Methods() []string {
+	return []string{MethodLorry, MethodCanalBoat, MethodHelicopter}
+}
+
+// Point is a location on the grid.
+type Point struct {
+	X float64
+	Y float64
+}
+
 type TripDetails struct {
 	Method   string
 	Duration time.Duration
-	Cost     float64
+	Cost     float64 // Price after any customer pricing agreement is applied
+	ListCost float64
 	Distance float64
+	Route    []Point // Path from the depot to the customer
 }
 
 func New(config *configuration.Config) *TransportHandler {
@@ -32,9 +53,33 @@ func (th *TransportHandler) CalculateCosts(customer customerhandler.Customer) []
 	transportMethods = append(transportMethods, th.calculateCanalBoat(customer))
 	transportMethods = append(transportMethods, th.calculateHelicopter(customer))
 
+	for _, trip := range transportMethods {
+		trip.ListCost = trip.Cost
+		trip.Cost = applyPricing(customer.Pricing, trip)
+	}
+
 	return transportMethods
 }
 
+// Apply a customer's pricing agreement to the list price of a trip.
+// A rate card for the method replaces the list price, then the discount, surcharge and minimum charge apply in turn.
+func applyPricing(pricing *customerhandler.PricingAgreement, trip *TripDetails) float64 {
+	if pricing == nil {
+		return trip.ListCost
+	}
+
+	cost := trip.ListCost
+
+	if rateCard, ok := pricing.RateCards[trip.Method]; ok {
+		cost = rateCard.BaseCharge + (rateCard.PerDistanceUnit * trip.Distance)
+	}
+
+	cost *= 1 - (pricing.DiscountPercent / 100)
+	cost += pricing.Surcharge
+
+	return math.Max(cost, pricing.MinimumCharge)
+}
+
 //nolint:nonamedreturns // Named returns for clarity with same type
 func (th *TransportHandler) calculateXYDistances(customer customerhandler.Customer) (x float64, y float64) {
 	diffX := math.Abs(float64(th.config.Company.GridX) - float64(customer.GridX))
@@ -43,13 +88,51 @@ func (th *TransportHandler) calculateXYDistances(customer customerhandler.Custom
 	return diffX, diffY
 }
 
+// Straight line distance from the depot to the customer, in grid units. If the customer and depot both have
+// real-world coordinates, the great-circle distance between them is used instead of the grid distance.
 func (th *TransportHandler) calculateDirectDistance(customer customerhandler.Customer) float64 {
+	projection := th.config.GridProjection()
+	depotCoordinates := th.config.DepotCoordinates()
+
+	if customer.Coordinates != nil && depotCoordinates != nil && projection.Enabled() {
+		return geohandler.HaversineKilometres(*depotCoordinates, *customer.Coordinates) / projection.KilometresPerUnit
+	}
+
 	diffX := float64(th.config.Company.GridX) - float64(customer.GridX)
 	diffY := float64(th.config.Company.GridY) - float64(customer.GridY)
 
 	return math.Sqrt(math.Pow(diffX, 2) + math.Pow(diffY, 2))
 }
 
+func (th *TransportHandler) depot() Point {
+	return Point{X: float64(th.config.Company.GridX), Y: float64(th.config.Company.GridY)}
+}
+
+// Lorries and canal boats travel along the grid, first in X then in Y.
+func (th *TransportHandler) gridRoute(customer customerhandler.Customer) []Point {
+	depot := th.depot()
+
+	return []Point{
+		depot,
+		{X: float64(customer.GridX), Y: depot.Y},
+		{X: float64(customer.GridX), Y: float64(customer.GridY)},
+	}
+}
+
+// Helicopters fly directly from the depot to the customer.
+func (th *TransportHandler) directRoute(customer customerhandler.Customer) []Point {
+	return []Point{th.depot(), {X: float64(customer.GridX), Y: float64(customer.GridY)}}
+}
+
+// Route returns the path a transport method takes from the depot to a customer.
+func (th *TransportHandler) Route(customer customerhandler.Customer, method string) []Point {
+	if method == MethodHelicopter {
+		return th.directRoute(customer)
+	}
+
+	return th.gridRoute(customer)
+}
+
 func (th *TransportHandler) calculateLorry(customer customerhandler.Customer) *TripDetails {
 	diffX, diffY := th.calculateXYDistances(customer)
 
@@ -67,10 +150,11 @@ func (th *TransportHandler) calculateLorry(customer customerhandler.Customer) *T
 	cost := (1.0 / 12.0) * (math.Pow(totalDist, 2) - float64(95*totalDist) + 2880)
 
 	return &TripDetails{
-		Method:   "Lorry",
+		Method:   MethodLorry,
 		Duration: totalTimeDuration,
 		Cost:     cost,
 		Distance: diffX + diffY,
+		Route:    th.gridRoute(customer),
 	}
 }
 
@@ -88,10 +172,11 @@ func (th *TransportHandler) calculateCanalBoat(customer customerhandler.Customer
 	cost := ((5 * totalDist) / 12.0) + (1280.0 / 12.0)
 
 	return &TripDetails{
-		Method:   "Canal boat",
+		Method:   MethodCanalBoat,
 		Duration: totalTimeDuration,
 		Cost:     cost,
 		Distance: diffX + diffY,
+		Route:    th.gridRoute(customer),
 	}
 }
 
@@ -110,9 +195,10 @@ func (th *TransportHandler) calculateHelicopter(customer customerhandler.Custome
 	cost := (0.5 * totalDist) + 195
 
 	return &TripDetails{
-		Method:   "Helicopter",
+		Method:   MethodHelicopter,
 		Duration: totalTimeDuration,
 		Cost:     cost,
 		Distance: totalDist,
+		Route:    th.directRoute(customer),
 	}
 }
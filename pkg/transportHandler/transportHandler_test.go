@@ -0,0 +1,55 @@
+package transporthandler
+
+import (
+	"math"
+	"testing"
+	customerhandler "work-mini-project/pkg/customerHandler"
+)
+
+func TestApplyPricing(t *testing.T) {
+	lorryRates := map[string]customerhandler.RateCard{
+		MethodLorry: {BaseCharge: 20, PerDistanceUnit: 3},
+	}
+
+	tests := []struct {
+		name    string
+		pricing *customerhandler.PricingAgreement
+		want    float64
+	}{
+		{"no agreement", nil, 100},
+		{"empty agreement", &customerhandler.PricingAgreement{}, 100},
+		{"discount", &customerhandler.PricingAgreement{DiscountPercent: 10}, 90},
+		{"full discount", &customerhandler.PricingAgreement{DiscountPercent: 100}, 0},
+		{"surcharge", &customerhandler.PricingAgreement{Surcharge: 5}, 105},
+		{"discount before surcharge", &customerhandler.PricingAgreement{DiscountPercent: 10, Surcharge: 5}, 95},
+		{"minimum charge applies", &customerhandler.PricingAgreement{MinimumCharge: 120}, 120},
+		{"minimum charge below price", &customerhandler.PricingAgreement{MinimumCharge: 50}, 100},
+		{
+			"minimum charge after discount",
+			&customerhandler.PricingAgreement{DiscountPercent: 100, MinimumCharge: 25},
+			25,
+		},
+		{"rate card replaces list price", &customerhandler.PricingAgreement{RateCards: lorryRates}, 50},
+		{
+			"rate card for another method",
+			&customerhandler.PricingAgreement{
+				RateCards: map[string]customerhandler.RateCard{MethodHelicopter: {BaseCharge: 1}},
+			},
+			100,
+		},
+		{
+			"rate card then discount and surcharge",
+			&customerhandler.PricingAgreement{RateCards: lorryRates, DiscountPercent: 50, Surcharge: 5},
+			30,
+		},
+	}
+
+	for _, test := range tests {
+		trip := &TripDetails{Method: MethodLorry, ListCost: 100, Distance: 10}
+
+		// Allow for floating point rounding in the percentage discount
+		if got := applyPricing(test.pricing, trip); math.Abs(got-test.want) > 1e-9 {
+			t.Errorf("%s: applyPricing() = %v, want %v", test.name, got, test.want)
+		}
+	}
+}